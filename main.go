@@ -12,11 +12,17 @@ import (
 )
 
 func main() {
-	var pathFlag string
-	flag.StringVar(&pathFlag, "i", "", "input image path (optional, otherwise pick in TUI)")
+	var pathFlag, charsetFlag string
+	flag.StringVar(&pathFlag, "i", "", "input image path, - for stdin (optional, otherwise pick in TUI)")
+	flag.StringVar(&charsetFlag, "charsets", tui.CharsetDir(), "directory of *.ramp files to load as charsets")
 	flag.Parse()
 
+	if err := tui.LoadCharsets(charsetFlag); err != nil {
+		fmt.Fprintln(os.Stderr, "warning:", err)
+	}
+
 	var m *tui.Model
+	opts := []tea.ProgramOption{tea.WithAltScreen()}
 
 	if strings.TrimSpace(pathFlag) != "" {
 		img, err := tui.LoadImage(pathFlag)
@@ -24,8 +30,15 @@ func main() {
 			fmt.Fprintln(os.Stderr, "error:", err)
 			os.Exit(1)
 		}
-		m = tui.NewViewerModel(img, filepath.Base(pathFlag))
-		m.Dir = filepath.Dir(pathFlag)
+		if pathFlag == "-" {
+			m = tui.NewViewerModel(img, "stdin")
+			m.Dir = "."
+			// stdin carried the image, keys come from the terminal
+			opts = append(opts, tea.WithInputTTY())
+		} else {
+			m = tui.NewViewerModel(img, filepath.Base(pathFlag))
+			m.Dir = filepath.Dir(pathFlag)
+		}
 	} else {
 		// start in picker
 		dir, err := os.Getwd()
@@ -41,7 +54,7 @@ func main() {
 		m = tui.NewPickerModel(dir, files)
 	}
 
-	p := tea.NewProgram(m, tea.WithAltScreen())
+	p := tea.NewProgram(m, opts...)
 	if _, err := p.Run(); err != nil {
 		fmt.Fprintln(os.Stderr, "tui error:", err)
 		os.Exit(1)
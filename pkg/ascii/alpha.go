@@ -0,0 +1,71 @@
+package ascii
+
+import "image/color"
+
+type AlphaMode int
+
+const (
+	AlphaIgnore AlphaMode = iota // drop alpha, hidden RGB shows up as is
+	AlphaMatte                   // composite over ConvertConfig.Matte
+	AlphaKeep                    // keep alpha for exporters that support it
+
+	alphaModeCount
+)
+
+// AlphaModes lists every alpha mode in order.
+func AlphaModes() []AlphaMode {
+	return enumValues(alphaModeCount)
+}
+
+// Cells less opaque than this are treated as fully transparent. Resampling
+// leaves faint alpha around shapes, so exact zero is too strict.
+const alphaCutoff = 8
+
+// applyAlpha prepares a resampled pixel for the configured alpha mode.
+func applyAlpha(c color.NRGBA, cfg ConvertConfig) color.NRGBA {
+	switch cfg.Alpha {
+	case AlphaMatte:
+		if c.A < alphaCutoff {
+			return color.NRGBA{R: cfg.Matte.R, G: cfg.Matte.G, B: cfg.Matte.B}
+		}
+		a := float64(c.A) / 255.0
+		blend := func(v, m uint8) uint8 {
+			return uint8(float64(v)*a + float64(m)*(1-a) + 0.5)
+		}
+		return color.NRGBA{
+			R: blend(c.R, cfg.Matte.R),
+			G: blend(c.G, cfg.Matte.G),
+			B: blend(c.B, cfg.Matte.B),
+			A: 255,
+		}
+	case AlphaKeep:
+		return c
+	default:
+		c.A = 255
+		return c
+	}
+}
+
+// transparent reports whether a cell of alpha a should be left empty.
+func transparent(a uint8) bool {
+	return a < alphaCutoff
+}
+
+// clearTransparent blanks every cell whose foreground is transparent and
+// returns the per-cell alpha.
+func clearTransparent(out *cells) []uint8 {
+	alpha := make([]uint8, len(out.chars))
+	for i, c := range out.fg {
+		alpha[i] = c.A
+		if out.bg != nil && out.bg[i].A > alpha[i] {
+			alpha[i] = out.bg[i].A
+		}
+		if transparent(alpha[i]) {
+			out.chars[i] = ' '
+			if out.bg != nil {
+				out.bg[i].A = 0
+			}
+		}
+	}
+	return alpha
+}
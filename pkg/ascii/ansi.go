@@ -0,0 +1,103 @@
+package ascii
+
+import (
+	"image/color"
+	"math"
+	"strings"
+	"unicode"
+)
+
+// perceptualDistance scales colorDistance back to roughly per-channel units,
+// so a tolerance of 4 merges colors about 4 steps apart.
+func perceptualDistance(a, b color.NRGBA) float64 {
+	return math.Sqrt(colorDistance(a, b)) / 3
+}
+
+// blank reports whether ch draws no ink, so its foreground color is moot.
+func blank(ch rune) bool {
+	return unicode.IsSpace(ch) || ch == '⠀'
+}
+
+// ansiPen tracks the colors the terminal currently has set so the encoder
+// only writes escape codes when they actually change.
+type ansiPen struct {
+	profile   ColorProfile
+	tolerance float64
+
+	fg, bg       color.NRGBA
+	hasFg, hasBg bool
+}
+
+func (p *ansiPen) matches(set bool, cur, next color.NRGBA) bool {
+	if !set {
+		return false
+	}
+	switch p.profile {
+	case ColorProfileANSI256:
+		return ansi256Index(cur) == ansi256Index(next)
+	case ColorProfileANSI16:
+		return ansi16Index(cur) == ansi16Index(next)
+	default:
+		return perceptualDistance(cur, next) <= p.tolerance
+	}
+}
+
+func (p *ansiPen) reset() {
+	p.hasFg, p.hasBg = false, false
+}
+
+// encodeANSI writes r as colored terminal text. Runs of cells whose colors
+// stay within r.Tolerance of the last emitted color share one escape code,
+// blank cells carry no foreground and every line ends with a reset.
+func encodeANSI(r *AsciiResult) string {
+	var b strings.Builder
+	b.Grow(r.Width*r.Height + r.Height)
+
+	pen := ansiPen{profile: r.Profile, tolerance: r.Tolerance}
+	params := make([]string, 0, 2)
+
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			i := r.index(x, y)
+			ch := r.Chars[i]
+			params = params[:0]
+
+			if r.Backgrounds != nil {
+				bg := r.Backgrounds[i]
+				switch {
+				case bg.A == 0:
+					// transparent: fall back to the terminal's own background
+					if pen.hasBg {
+						params = append(params, "49")
+						pen.hasBg = false
+					}
+				case !pen.matches(pen.hasBg, pen.bg, bg):
+					params = append(params, r.Profile.sgrBg(bg))
+					pen.bg, pen.hasBg = bg, true
+				}
+			}
+
+			if !blank(ch) {
+				fg := r.Colors[i]
+				if !pen.matches(pen.hasFg, pen.fg, fg) {
+					params = append(params, r.Profile.sgrFg(fg))
+					pen.fg, pen.hasFg = fg, true
+				}
+			}
+
+			if len(params) > 0 {
+				b.WriteString("\x1b[")
+				b.WriteString(strings.Join(params, ";"))
+				b.WriteByte('m')
+			}
+			b.WriteRune(ch)
+		}
+
+		if pen.hasFg || pen.hasBg {
+			b.WriteString("\x1b[0m")
+			pen.reset()
+		}
+		b.WriteByte('\n')
+	}
+	return b.String()
+}
@@ -0,0 +1,112 @@
+package ascii
+
+import (
+	"image/color"
+	"strings"
+	"testing"
+)
+
+// perCellANSI is the encoder encodeANSI replaced: one truecolor escape per
+// cell.
+func perCellANSI(r *AsciiResult) string {
+	var b strings.Builder
+	for y := 0; y < r.Height; y++ {
+		for x := 0; x < r.Width; x++ {
+			i := r.index(x, y)
+			b.WriteString("\x1b[")
+			b.WriteString(ColorProfileTrueColor.sgrFg(r.Colors[i]))
+			b.WriteByte('m')
+			b.WriteRune(r.Chars[i])
+		}
+		b.WriteByte('\n')
+	}
+	b.WriteString("\x1b[0m")
+	return b.String()
+}
+
+// ansiFixture is a 64×16 result of horizontal color bands with a little
+// per-cell jitter, the way a photo's flat regions come out.
+func ansiFixture(tolerance float64) *AsciiResult {
+	const w, h = 64, 16
+	r := &AsciiResult{
+		Width:     w,
+		Height:    h,
+		Chars:     make([]rune, w*h),
+		Colors:    make([]color.NRGBA, w*h),
+		Colored:   true,
+		Profile:   ColorProfileTrueColor,
+		Tolerance: tolerance,
+	}
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			band := uint8(x / 16 * 60)
+			jitter := uint8((x*7 + y*3) % 3)
+			r.Chars[i] = '#'
+			r.Colors[i] = color.NRGBA{R: band + jitter, G: 100 + jitter, B: 200 - band, A: 255}
+		}
+	}
+	return r
+}
+
+func TestEncodeANSISmallerThanPerCell(t *testing.T) {
+	r := ansiFixture(4)
+	merged, perCell := len(encodeANSI(r)), len(perCellANSI(r))
+	if merged >= perCell {
+		t.Fatalf("merged output %d bytes, per-cell %d", merged, perCell)
+	}
+	t.Logf("merged %d bytes, per-cell %d (%.0f%%)", merged, perCell, 100*float64(merged)/float64(perCell))
+}
+
+func TestEncodeANSIMergesRunsWithinTolerance(t *testing.T) {
+	r := ansiFixture(4)
+	out := encodeANSI(r)
+
+	// four bands per line, one escape each
+	for y, line := range strings.Split(strings.TrimSuffix(out, "\n"), "\n") {
+		if n := strings.Count(line, "\x1b[38;2;"); n != 4 {
+			t.Errorf("line %d: %d foreground escapes, want 4", y, n)
+		}
+	}
+
+	r.Tolerance = 0
+	if strict := encodeANSI(r); strings.Count(strict, "\x1b[38;2;") <= strings.Count(out, "\x1b[38;2;") {
+		t.Error("tolerance 0 should not merge jittered colors")
+	}
+}
+
+func TestEncodeANSIResetsEveryLine(t *testing.T) {
+	r := ansiFixture(4)
+	r.Backgrounds = make([]color.NRGBA, len(r.Colors))
+	for i := range r.Backgrounds {
+		r.Backgrounds[i] = color.NRGBA{R: 10, G: 10, B: 10, A: 255}
+	}
+
+	lines := strings.Split(strings.TrimSuffix(encodeANSI(r), "\n"), "\n")
+	if len(lines) != r.Height {
+		t.Fatalf("%d lines, want %d", len(lines), r.Height)
+	}
+	for y, line := range lines {
+		if !strings.HasSuffix(line, "\x1b[0m") {
+			t.Errorf("line %d does not end with a reset: %q", y, line[max(0, len(line)-12):])
+		}
+	}
+}
+
+func BenchmarkEncodeANSI(b *testing.B) {
+	r := ansiFixture(4)
+	b.Run("merged", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			n = len(encodeANSI(r))
+		}
+		b.ReportMetric(float64(n), "output-bytes")
+	})
+	b.Run("per-cell", func(b *testing.B) {
+		var n int
+		for b.Loop() {
+			n = len(perCellANSI(r))
+		}
+		b.ReportMetric(float64(n), "output-bytes")
+	})
+}
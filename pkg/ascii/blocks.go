@@ -0,0 +1,299 @@
+package ascii
+
+import (
+	"image"
+	"image/color"
+)
+
+// cells is the per-cell output of a rendering mode.
+type cells struct {
+	chars []rune
+	gray  []float64 // average brightness of each cell
+	fg    []color.NRGBA
+	bg    []color.NRGBA // nil when the mode sets no background
+}
+
+func newCells(n int, withBg bool) *cells {
+	c := &cells{
+		chars: make([]rune, n),
+		gray:  make([]float64, n),
+		fg:    make([]color.NRGBA, n),
+	}
+	if withBg {
+		c.bg = make([]color.NRGBA, n)
+	}
+	return c
+}
+
+// halfBlockCells packs two vertical pixels into every cell. In color the
+// upper pixel becomes the foreground of '▀' and the lower one its
+// background; without color the pixels are dithered to on/off and drawn
+// with ' ', '▀', '▄' or '█'.
+func halfBlockCells(img image.Image, w, h int, cfg ConvertConfig) *cells {
+	gray, pixels := sample(img, w, h*2, cfg)
+	out := newCells(w*h, cfg.Colored)
+
+	if !cfg.Colored {
+		cfg.Dithering.ApplyOptions(gray, w, h*2, 2, cfg.ditherOptions())
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			top := (2*y)*w + x
+			bottom := (2*y+1)*w + x
+
+			out.fg[i] = pixels[top]
+			out.gray[i] = (getBrightness(pixels[top].R, pixels[top].G, pixels[top].B, cfg.Luminance) +
+				getBrightness(pixels[bottom].R, pixels[bottom].G, pixels[bottom].B, cfg.Luminance)) / 2
+
+			if cfg.Colored {
+				// with alpha kept, a transparent half is drawn as no color
+				// at all rather than as a background
+				upperClear := transparent(pixels[top].A)
+				lowerClear := transparent(pixels[bottom].A)
+				switch {
+				case upperClear && !lowerClear:
+					out.chars[i] = '▄'
+					out.fg[i] = pixels[bottom]
+					out.bg[i] = color.NRGBA{}
+				case lowerClear && !upperClear:
+					out.chars[i] = '▀'
+					out.bg[i] = color.NRGBA{}
+				default:
+					out.chars[i] = '▀'
+					out.bg[i] = pixels[bottom]
+				}
+				continue
+			}
+
+			upper := gray[top] >= 128
+			lower := gray[bottom] >= 128
+			if cfg.Inverted {
+				upper, lower = !upper, !lower
+			}
+			switch {
+			case upper && lower:
+				out.chars[i] = '█'
+			case upper:
+				out.chars[i] = '▀'
+			case lower:
+				out.chars[i] = '▄'
+			default:
+				out.chars[i] = ' '
+			}
+		}
+	}
+	return out
+}
+
+// Bit of each dot in a 2×4 Braille cell, indexed [y][x].
+var brailleBits = [4][2]rune{
+	{0x01, 0x08},
+	{0x02, 0x10},
+	{0x04, 0x20},
+	{0x40, 0x80},
+}
+
+// brailleCells maps every 2×4 pixel block onto a Braille pattern
+// (U+2800–U+28FF). The pixels are dithered to on/off with the configured
+// strategy and the cell takes the average color of its block.
+func brailleCells(img image.Image, w, h int, cfg ConvertConfig) *cells {
+	pw := w * 2
+	gray, pixels := sample(img, pw, h*4, cfg)
+	out := newCells(w*h, false)
+
+	source := make([]float64, len(gray))
+	copy(source, gray)
+	cfg.Dithering.ApplyOptions(gray, pw, h*4, 2, cfg.ditherOptions())
+
+	for cy := 0; cy < h; cy++ {
+		for cx := 0; cx < w; cx++ {
+			pattern := rune(0)
+			var r, g, b, a, lum float64
+
+			for y := 0; y < 4; y++ {
+				for x := 0; x < 2; x++ {
+					i := (cy*4+y)*pw + cx*2 + x
+					on := gray[i] >= 128
+					if cfg.Inverted {
+						on = !on
+					}
+					if on {
+						pattern |= brailleBits[y][x]
+					}
+
+					r += float64(pixels[i].R)
+					g += float64(pixels[i].G)
+					b += float64(pixels[i].B)
+					a += float64(pixels[i].A)
+					lum += source[i]
+				}
+			}
+
+			ci := cy*w + cx
+			out.chars[ci] = 0x2800 + pattern
+			out.fg[ci] = color.NRGBA{
+				R: uint8(r / 8),
+				G: uint8(g / 8),
+				B: uint8(b / 8),
+				A: uint8(a / 8),
+			}
+			out.gray[ci] = lum / 8
+		}
+	}
+	return out
+}
+
+// Quadrant block elements indexed by pattern; bit 0 is the top-left
+// sub-pixel, then top-right, bottom-left, bottom-right.
+var quadrantGlyphs = [16]rune{
+	' ', '▘', '▝', '▀', '▖', '▌', '▞', '▛',
+	'▗', '▚', '▐', '▜', '▄', '▙', '▟', '█',
+}
+
+func quadrantGlyph(pattern int) rune {
+	return quadrantGlyphs[pattern]
+}
+
+// sextantGlyph maps a 2×3 pattern (bit 0 top-left, row-major) onto the
+// Unicode 13 sextants. The block skips the four patterns that already exist
+// as older block elements.
+func sextantGlyph(pattern int) rune {
+	switch pattern {
+	case 0:
+		return ' '
+	case 0b010101:
+		return '▌'
+	case 0b101010:
+		return '▐'
+	case 0b111111:
+		return '█'
+	}
+
+	offset := pattern - 1
+	if pattern > 0b010101 {
+		offset--
+	}
+	if pattern > 0b101010 {
+		offset--
+	}
+	return 0x1FB00 + rune(offset)
+}
+
+// mosaicCells renders every cell as an sx×sy grid of sub-pixels drawn with
+// glyph(pattern). In color each cell is split into the two-color pattern
+// that best fits its sub-pixels, with the set bits as foreground; without
+// color the sub-pixels are dithered to on/off.
+func mosaicCells(img image.Image, w, h, sx, sy int, glyph func(int) rune, cfg ConvertConfig) *cells {
+	pw := w * sx
+	gray, pixels := sample(img, pw, h*sy, cfg)
+	out := newCells(w*h, cfg.Colored)
+
+	source := make([]float64, len(gray))
+	copy(source, gray)
+	if !cfg.Colored {
+		cfg.Dithering.ApplyOptions(gray, pw, h*sy, 2, cfg.ditherOptions())
+	}
+
+	n := sx * sy
+	block := make([]color.NRGBA, n)
+
+	for cy := 0; cy < h; cy++ {
+		for cx := 0; cx < w; cx++ {
+			pattern := 0
+			var lum float64
+
+			for y := 0; y < sy; y++ {
+				for x := 0; x < sx; x++ {
+					i := (cy*sy+y)*pw + cx*sx + x
+					bit := y*sx + x
+					block[bit] = pixels[i]
+					lum += source[i]
+
+					on := gray[i] >= 128
+					if cfg.Inverted {
+						on = !on
+					}
+					if on {
+						pattern |= 1 << bit
+					}
+				}
+			}
+
+			ci := cy*w + cx
+			out.gray[ci] = lum / float64(n)
+
+			if cfg.Colored {
+				pattern, out.fg[ci], out.bg[ci] = bestPartition(block)
+			} else {
+				out.fg[ci] = averageColor(block, 1<<n-1)
+			}
+			out.chars[ci] = glyph(pattern)
+		}
+	}
+	return out
+}
+
+// bestPartition tries every split of block into a foreground and a
+// background set and returns the one with the least squared color error,
+// along with the mean color of each side.
+func bestPartition(block []color.NRGBA) (pattern int, fg, bg color.NRGBA) {
+	full := 1<<len(block) - 1
+	bestErr := -1.0
+
+	// a pattern and its complement fit equally well, so only patterns with
+	// the last sub-pixel in the foreground are tried. Going down from the
+	// full block lets flat cells settle on '█' instead of a random split.
+	top := 1 << (len(block) - 1)
+	for p := full; p >= top; p-- {
+		f := averageColor(block, p)
+		b := averageColor(block, full&^p)
+
+		var sum float64
+		for i, c := range block {
+			ref := b
+			if p&(1<<i) != 0 {
+				ref = f
+			}
+			dr := float64(c.R) - float64(ref.R)
+			dg := float64(c.G) - float64(ref.G)
+			db := float64(c.B) - float64(ref.B)
+			sum += dr*dr + dg*dg + db*db
+		}
+
+		if bestErr < 0 || sum < bestErr {
+			bestErr = sum
+			pattern, fg, bg = p, f, b
+		}
+	}
+
+	if pattern == full {
+		bg = fg
+	}
+	return pattern, fg, bg
+}
+
+// averageColor returns the mean color of the sub-pixels selected by mask.
+func averageColor(block []color.NRGBA, mask int) color.NRGBA {
+	var r, g, b, a, count float64
+	for i, c := range block {
+		if mask&(1<<i) == 0 {
+			continue
+		}
+		r += float64(c.R)
+		g += float64(c.G)
+		b += float64(c.B)
+		a += float64(c.A)
+		count++
+	}
+	if count == 0 {
+		return color.NRGBA{A: 255}
+	}
+	return color.NRGBA{
+		R: uint8(r / count),
+		G: uint8(g / count),
+		B: uint8(b / count),
+		A: uint8(a / count),
+	}
+}
@@ -0,0 +1,119 @@
+package ascii
+
+import (
+	"math"
+	"sort"
+)
+
+// Bitmap size glyph coverage is measured at
+const (
+	calibrationW = 10
+	calibrationH = 20
+)
+
+// Glyphs whose normalized coverage differs by less than this count as
+// equally dense, and only the first of them is kept.
+const calibrationEpsilon = 0.004
+
+// calibratedRamp is a ramp sorted by measured ink coverage, with the
+// brightness (0–255, ascending) each glyph stands for.
+type calibratedRamp struct {
+	runes  []rune
+	levels []float64
+}
+
+// calibrateRamp measures the coverage of every glyph of ramp, drops
+// repeated runes and glyphs as dense as an earlier one and stretches the
+// coverages over 0–255. The ramp keeps its direction: if its first glyph
+// has more ink than its last, dark pixels still get the dense glyphs.
+// Inverted ramps are reversed. ok is false if fewer than two distinct
+// densities remain.
+func calibrateRamp(ramp []rune, inverted bool) (cal calibratedRamp, ok bool, err error) {
+	type glyph struct {
+		r        rune
+		coverage float64
+		measured bool
+		position float64 // place in the ramp, 0–1
+	}
+
+	glyphs := make([]glyph, 0, len(ramp))
+	seen := map[rune]bool{}
+	lo, hi := math.Inf(1), math.Inf(-1)
+	for i, r := range ramp {
+		if seen[r] {
+			continue
+		}
+		seen[r] = true
+
+		mask, err := glyphMask(r, calibrationW, calibrationH)
+		if err != nil {
+			return calibratedRamp{}, false, err
+		}
+		g := glyph{r: r, measured: mask != nil}
+		if len(ramp) > 1 {
+			g.position = float64(i) / float64(len(ramp)-1)
+		}
+		if g.measured {
+			g.coverage = mean(mask)
+			lo = math.Min(lo, g.coverage)
+			hi = math.Max(hi, g.coverage)
+		}
+		glyphs = append(glyphs, g)
+	}
+	if hi-lo <= 0 {
+		return calibratedRamp{}, false, nil
+	}
+
+	// the first and last measured glyphs tell which way the ramp runs
+	var first, last *glyph
+	for i := range glyphs {
+		if glyphs[i].measured {
+			if first == nil {
+				first = &glyphs[i]
+			}
+			last = &glyphs[i]
+		}
+	}
+	denseFirst := first.coverage > last.coverage
+
+	// from here on coverage is the brightness (0–1) the glyph stands for
+	for i, g := range glyphs {
+		switch {
+		case !g.measured:
+			// unknown to the font: trust its position in the ramp
+			glyphs[i].coverage = g.position
+		case denseFirst:
+			glyphs[i].coverage = (hi - g.coverage) / (hi - lo)
+		default:
+			glyphs[i].coverage = (g.coverage - lo) / (hi - lo)
+		}
+	}
+
+	sort.SliceStable(glyphs, func(i, j int) bool {
+		return glyphs[i].coverage < glyphs[j].coverage
+	})
+
+	for _, g := range glyphs {
+		if n := len(cal.levels); n > 0 && g.coverage*255-cal.levels[n-1] < calibrationEpsilon*255 {
+			// stable sort keeps the earlier rune of a tie first
+			continue
+		}
+		cal.runes = append(cal.runes, g.r)
+		cal.levels = append(cal.levels, g.coverage*255)
+	}
+	if len(cal.runes) < 2 {
+		return calibratedRamp{}, false, nil
+	}
+
+	if inverted {
+		n := len(cal.runes)
+		for i, j := 0, n-1; i < j; i, j = i+1, j-1 {
+			cal.runes[i], cal.runes[j] = cal.runes[j], cal.runes[i]
+			cal.levels[i], cal.levels[j] = cal.levels[j], cal.levels[i]
+		}
+		for i := range cal.levels {
+			cal.levels[i] = 255 - cal.levels[i]
+		}
+	}
+	return cal, true, nil
+}
@@ -0,0 +1,116 @@
+package ascii
+
+import (
+	"image"
+	"image/color"
+	"testing"
+)
+
+func uniform(v uint8) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, 40, 40))
+	for i := 0; i < len(img.Pix); i += 4 {
+		img.Pix[i], img.Pix[i+1], img.Pix[i+2], img.Pix[i+3] = v, v, v, 255
+	}
+	return img
+}
+
+// Calibration reorders glyphs by ink but must keep which end of the ramp
+// dark pixels get: the blank end stays blank, the other end draws ink.
+func TestCalibrateKeepsRampDirection(t *testing.T) {
+	tests := []struct {
+		charset    CharSet
+		blankPixel uint8
+	}{
+		{CharSetClassic, 0},
+		{CharSetMinimal, 255},
+	}
+
+	for _, tt := range tests {
+		for _, calibrate := range []bool{false, true} {
+			convert := func(v uint8) rune {
+				cfg := DefaultConfig()
+				cfg.Charset = tt.charset
+				cfg.Calibrate = calibrate
+				res, err := ConvertImage(uniform(v), cfg)
+				if err != nil {
+					t.Fatal(err)
+				}
+				return res.Chars[0]
+			}
+
+			if got := convert(tt.blankPixel); got != ' ' {
+				t.Errorf("%s calibrate=%v pixel %d: got %q, want ' '", tt.charset.Name(), calibrate, tt.blankPixel, got)
+			}
+			if got := convert(255 - tt.blankPixel); got == ' ' {
+				t.Errorf("%s calibrate=%v pixel %d: got ' ', want ink", tt.charset.Name(), calibrate, 255-tt.blankPixel)
+			}
+		}
+	}
+}
+
+func TestCalibrateInvertedMirrors(t *testing.T) {
+	for _, cs := range []CharSet{CharSetClassic, CharSetMinimal} {
+		ramp := []rune(cs.Ramp())
+		normal, ok, err := calibrateRamp(ramp, false)
+		if err != nil || !ok {
+			t.Fatalf("%s: ok=%v err=%v", cs.Name(), ok, err)
+		}
+		inverted, _, _ := calibrateRamp(ramp, true)
+		n := len(normal.runes)
+		if n != len(inverted.runes) || normal.runes[0] != inverted.runes[n-1] || normal.runes[n-1] != inverted.runes[0] {
+			t.Errorf("%s: inverted %q is not the reverse of %q", cs.Name(), string(inverted.runes), string(normal.runes))
+		}
+	}
+}
+
+// grayRamp is a w×4 ramp from black to white along x.
+func grayRamp(w int) image.Image {
+	img := image.NewNRGBA(image.Rect(0, 0, w, 4))
+	for y := range 4 {
+		for x := range w {
+			v := uint8(x * 255 / (w - 1))
+			img.SetNRGBA(x, y, color.NRGBA{R: v, G: v, B: v, A: 255})
+		}
+	}
+	return img
+}
+
+// A custom ramp is sorted and de-duplicated by density even without
+// Calibrate.
+func TestCustomRampSortedWithoutCalibrate(t *testing.T) {
+	const custom = "#. @:.# :@"
+	want, ok, err := calibrateRamp([]rune(custom), false)
+	if err != nil || !ok {
+		t.Fatalf("ok=%v err=%v", ok, err)
+	}
+
+	cfg := DefaultConfig()
+	cfg.Resolution = 1
+	cfg.CustomRamp = custom
+	res, err := ConvertImage(grayRamp(64), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rank := map[rune]int{}
+	for i, r := range want.runes {
+		rank[r] = i
+	}
+	used := map[rune]bool{}
+	prev := -1
+	for x := 0; x < res.Width; x++ {
+		r := res.Chars[x]
+		i, known := rank[r]
+		if !known {
+			t.Fatalf("column %d: %q is not in the sorted ramp %q", x, r, string(want.runes))
+		}
+		if i < prev {
+			t.Fatalf("column %d: %q is less dense than the column before", x, r)
+		}
+		prev = i
+		used[r] = true
+	}
+	if len(used) != len(want.runes) {
+		t.Errorf("used %d glyphs, sorted ramp %q has %d", len(used), string(want.runes), len(want.runes))
+	}
+}
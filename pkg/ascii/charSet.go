@@ -1,12 +1,28 @@
 package ascii
 
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"unicode"
+
+	"github.com/mattn/go-runewidth"
+)
+
 type CharSet int
 
 const (
-	CharSetClassic CharSet = iota // your original 16-ish level ramp
-	CharSetPhoto                  // long, smooth photographic ramp
-	CharSetMinimal                // @%#*+=-:. style
-	CharSetBlocks                 // " ░▒▓█" block characters
+	CharSetClassic    CharSet = iota // your original 16-ish level ramp
+	CharSetPhoto                     // long, smooth photographic ramp
+	CharSetMinimal                   // @%#*+=-:. style
+	CharSetBlocks                    // " ░▒▓█" block characters
+	CharSetHalfBlocks                // "▀" with fg/bg colors, two pixels per cell
+	CharSetBraille                   // U+2800 Braille patterns, 2×4 dots per cell
+	CharSetQuadrants                 // "▖▗▘▝▚▞▙▛▜▟" 2×2 sub-pixels with fg/bg colors
+	CharSetSextants                  // Unicode 13 sextants, 2×3 sub-pixels with fg/bg colors
 )
 
 const (
@@ -31,3 +47,141 @@ const (
 	asciiBlocks    = " ░▒▓█"
 	asciiBlocksInv = "█▓▒░ "
 )
+
+// File extension of ramps LoadCharsets picks up
+const rampFileExt = ".ramp"
+
+var (
+	ErrInvalidRamp      = errors.New("invalid ramp")
+	ErrDuplicateCharset = errors.New("charset name already registered")
+	ErrUnknownCharset   = errors.New("unknown charset")
+)
+
+// Ramps are measured in a fixed, locale-independent width table.
+var rampWidth = &runewidth.Condition{StrictEmojiNeutral: true}
+
+type charsetEntry struct {
+	name string
+	// empty for the sub-pixel modes, which draw their own glyphs
+	ramp string
+	// hand-written ramp for Inverted, empty to reverse ramp
+	inverted string
+}
+
+var charsets = newRegistry[CharSet](func(e charsetEntry) string { return e.name })
+
+func init() {
+	registerCharset(CharSetClassic, charsetEntry{"Classic", asciiClassic, asciiClassicInv})
+	registerCharset(CharSetPhoto, charsetEntry{"Photo", asciiPhoto, asciiPhotoInv})
+	registerCharset(CharSetMinimal, charsetEntry{"Minimal", asciiMinimal, asciiMinimalInv})
+	registerCharset(CharSetBlocks, charsetEntry{"Blocks", asciiBlocks, asciiBlocksInv})
+	registerCharset(CharSetHalfBlocks, charsetEntry{name: "HalfBlocks"})
+	registerCharset(CharSetBraille, charsetEntry{name: "Braille"})
+	registerCharset(CharSetQuadrants, charsetEntry{name: "Quadrants"})
+	registerCharset(CharSetSextants, charsetEntry{name: "Sextants"})
+}
+
+func registerCharset(cs CharSet, e charsetEntry) {
+	charsets.add(cs, e)
+}
+
+// ValidateRamp checks that ramp has at least two distinct runes, no
+// control characters and runes of one display width.
+func ValidateRamp(ramp string) error {
+	distinct := map[rune]bool{}
+	width := -1
+	for _, r := range ramp {
+		if r == unicode.ReplacementChar || unicode.IsControl(r) {
+			return fmt.Errorf("%w: control or invalid character %U", ErrInvalidRamp, r)
+		}
+		w := rampWidth.RuneWidth(r)
+		if w == 0 {
+			return fmt.Errorf("%w: zero-width character %U", ErrInvalidRamp, r)
+		}
+		if width >= 0 && w != width {
+			return fmt.Errorf("%w: %q is %d cells wide, earlier runes %d", ErrInvalidRamp, r, w, width)
+		}
+		width = w
+		distinct[r] = true
+	}
+	if len(distinct) < 2 {
+		return fmt.Errorf("%w: needs at least 2 distinct runes, got %d", ErrInvalidRamp, len(distinct))
+	}
+	return nil
+}
+
+// RegisterCharset makes a ramp, glyph for the darkest pixels first,
+// available under name and returns the CharSet that selects it. Names must
+// be unique.
+func RegisterCharset(name, ramp string) (CharSet, error) {
+	if err := ValidateRamp(ramp); err != nil {
+		return 0, err
+	}
+	return charsets.register(charsetEntry{name: name, ramp: ramp}, ErrDuplicateCharset)
+}
+
+// LoadCharsets registers every *.ramp file in dir, named after the file.
+// The file holds the ramp itself; a trailing newline is ignored. Files
+// that fail are skipped and reported in the joined error.
+func LoadCharsets(dir string) ([]CharSet, error) {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, fmt.Errorf("read charset dir: %w", err)
+	}
+
+	names := make([]string, 0, len(entries))
+	for _, e := range entries {
+		if !e.IsDir() && strings.EqualFold(filepath.Ext(e.Name()), rampFileExt) {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	var loaded []CharSet
+	var errs []error
+	for _, fn := range names {
+		data, err := os.ReadFile(filepath.Join(dir, fn))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("load charset %s: %w", fn, err))
+			continue
+		}
+		ramp := strings.TrimSuffix(strings.TrimSuffix(string(data), "\n"), "\r")
+
+		cs, err := RegisterCharset(strings.TrimSuffix(fn, filepath.Ext(fn)), ramp)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("load charset %s: %w", fn, err))
+			continue
+		}
+		loaded = append(loaded, cs)
+	}
+	return loaded, errors.Join(errs...)
+}
+
+// CharSets lists every registered charset in registration order.
+func CharSets() []CharSet {
+	return charsets.ids()
+}
+
+// CharSetByName looks a charset up by name.
+func CharSetByName(name string) (CharSet, bool) {
+	return charsets.byName(name)
+}
+
+func (c CharSet) entry() (charsetEntry, bool) {
+	return charsets.get(c)
+}
+
+// Name returns the registered name, or "?" for unknown charsets.
+func (c CharSet) Name() string {
+	if e, ok := c.entry(); ok {
+		return e.name
+	}
+	return "?"
+}
+
+// Ramp returns the charset's ramp, glyph for the darkest pixels first.
+// Sub-pixel modes have none.
+func (c CharSet) Ramp() string {
+	e, _ := c.entry()
+	return e.ramp
+}
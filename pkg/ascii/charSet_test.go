@@ -0,0 +1,36 @@
+package ascii
+
+import "testing"
+
+// Inverted built-in charsets draw with their hand-written ramps, which are
+// not exact reverses of the normal ones.
+func TestInvertedUsesHandWrittenRamp(t *testing.T) {
+	tests := []struct {
+		cs   CharSet
+		want string
+	}{
+		{CharSetClassic, asciiClassicInv},
+		{CharSetPhoto, asciiPhotoInv},
+		{CharSetMinimal, asciiMinimalInv},
+		{CharSetBlocks, asciiBlocksInv},
+		{CharSetBraille, asciiClassicInv},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.Charset = tt.cs
+		if _, inv := cfg.ramps(); inv != tt.want {
+			t.Errorf("%s: inverted ramp %q, want %q", tt.cs.Name(), inv, tt.want)
+		}
+	}
+
+	cfg := DefaultConfig()
+	cfg.Charset = CharSetPhoto
+	cfg.Inverted = true
+	res, err := ConvertImage(uniform(0), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if res.Chars[0] != '@' {
+		t.Errorf("inverted Photo black pixel: got %q, want '@'", res.Chars[0])
+	}
+}
@@ -7,8 +7,6 @@ import (
 	"image/color"
 	"math"
 	"strings"
-
-	"github.com/disintegration/imaging"
 )
 
 // Default ASCII ramps
@@ -19,22 +17,119 @@ const asciiCharsInverted = "@80GCLft1i;:,. "
 type ConvertConfig struct {
 	// Scale factor for output (0.01–1.0)
 	Resolution float64
+	// EXIF orientation of the source, applied first (see ReadOrientation)
+	Orientation Orientation
+	// Clockwise rotation, applied after the orientation
+	Rotation Rotation
+	// Mirror left to right and top to bottom, after the rotation
+	FlipH, FlipV bool
+	// Region to convert, in pixels of the rotated and flipped image with
+	// its top-left corner at 0,0. The zero rectangle converts everything.
+	Crop image.Rectangle
 	// Contrast adjustment (0.1–3.0)
 	Contrast float64
 	// Brightness adjustment (0.1–3.0)
 	Brightness float64
+	// Apply contrast and brightness in linear light instead of on
+	// gamma-encoded values
+	GammaCorrect bool
+	// How a pixel's brightness is computed from its color
+	Luminance Luminance
+	// Filter the image is resized with
+	Resampling Resampling
+	// How a cell's color is computed from the source pixels it covers
+	ColorAggregation ColorAggregation
+	// Filters run in order on the resized image, before brightness is
+	// computed
+	Filters []Filter
+	// Automatic tone mapping of the brightness before characters are picked
+	ToneMapping ToneMapping
+	// Percentiles (0–100) ToneAutoLevels stretches to black and white
+	BlackPoint float64
+	WhitePoint float64
+	// CLAHE tile size in samples (0 means 8)
+	ClaheTile int
+	// CLAHE clip limit as a multiple of the average histogram bin (0 means
+	// 2.0, otherwise at least 1.0)
+	ClaheClip float64
 	// Invert the character mapping
 	Inverted bool
 	// Use colored output
 	Colored bool
 	// Dithering algorithm to use
 	Dithering DitheringStrategy
+	// Alternate the error-diffusion scan direction every row
+	Serpentine bool
+	// Fraction of the quantization error that is propagated (0.0–1.0)
+	DitherStrength float64
+	// Length of the Riemersma error history (0–256, 0 means 16)
+	RiemersmaHistory int
+	// Seed of the noise dithering strategies, blue noise included. The same
+	// seed reproduces the same output.
+	Seed uint64
 	// Character set to use
 	Charset CharSet
-	// Custom charter ramp (if Charset is Custom)
+	// Custom character ramp, glyph for the darkest pixels first. Overrides
+	// Charset when not blank. It is always sorted by measured ink coverage,
+	// and repeated glyphs and glyphs as dense as an earlier one are dropped.
 	CustomRamp string
+	// Map brightness to ramp glyphs by their measured ink coverage instead
+	// of evenly. Built-in ramps are sorted and de-duplicated like
+	// CustomRamp first.
+	Calibrate bool
+	// Replace ramp characters with direction glyphs (| / \ - _) on edges
+	EdgeDetection bool
+	// Edge strength threshold (0.0–1.0), relative to the strongest Sobel response
+	EdgeThreshold float64
+	// How each cell's character is chosen (brightness ramp or glyph shape)
+	Matching MatchMode
+	// Terminal color support ToANSI targets
+	ColorProfile ColorProfile
+	// Dither colors with the Dithering strategy when reducing them to a
+	// palette
+	ColorDither bool
+	// Custom palette colors are reduced to. Empty means the ColorProfile's
+	// palette, if any.
+	Palette []color.NRGBA
+	// Colors closer than this (0–255, roughly per channel) share one ANSI
+	// escape code
+	ColorTolerance float64
+	// Height:width ratio of a character cell (0.25–4.0, 0 means 2.0)
+	CellAspect float64
+	// How the output size is chosen
+	SizeMode SizeMode
+	// Output width in columns (SizeWidth, SizeFit)
+	TargetWidth int
+	// Output height in rows (SizeHeight, SizeFit)
+	TargetHeight int
+	// Cells per source pixel across and down in SizePixelArt (sub-pixels in
+	// the half-block, Braille, quadrant and sextant modes). 0 picks the
+	// smallest scale that keeps pixels square for the cell aspect. Braille
+	// needs multiples of 2×4, quadrants and sextants even x scales and
+	// sextants y scales from 2, so no cell blends pixels.
+	PixelScaleX, PixelScaleY int
+	// How transparent pixels are handled
+	Alpha AlphaMode
+	// Color transparent pixels are composited over (AlphaMatte)
+	Matte color.NRGBA
 }
 
+type SizeMode int
+
+const (
+	SizeScale    SizeMode = iota // scale the image by Resolution
+	SizeWidth                    // exactly TargetWidth columns
+	SizeHeight                   // exactly TargetHeight rows
+	SizeFit                      // largest size fitting TargetWidth×TargetHeight
+	SizePixelArt                 // every source pixel becomes PixelScaleX×PixelScaleY cells, unblended
+)
+
+// Default height:width ratio of a terminal character cell
+const defaultCellAspect = 2.0
+
+// Upper bound for TargetWidth and TargetHeight
+const maxTargetSize = 10000
+
 func DefaultConfig() ConvertConfig {
 	return ConvertConfig{
 		Resolution: 0.2,
@@ -44,6 +139,12 @@ func DefaultConfig() ConvertConfig {
 		Colored:    true,
 		Dithering:  DitheringNone,
 		Charset:    CharSetPhoto,
+		CellAspect: defaultCellAspect,
+
+		DitherStrength: 1.0,
+		BlackPoint:     1,
+		WhitePoint:     99,
+		EdgeThreshold:  0.25,
 	}
 }
 
@@ -52,50 +153,234 @@ var (
 	ErrInvalidContrast   = errors.New("contrast must be in [0.1, 3.0]")
 	ErrInvalidBrightness = errors.New("brightness must be in [0.1, 3.0]")
 	ErrImageTooSmall     = errors.New("image too small after scaling")
+
+	ErrInvalidEdgeThreshold  = errors.New("edge threshold must be in [0.0, 1.0]")
+	ErrInvalidTolerance      = errors.New("color tolerance must be in [0, 255]")
+	ErrInvalidCellAspect     = errors.New("cell aspect must be in [0.25, 4.0]")
+	ErrInvalidSizeMode       = errors.New("unknown size mode")
+	ErrMissingTarget         = errors.New("size mode needs a positive target")
+	ErrInvalidTarget         = errors.New("target size must be in [0, 10000]")
+	ErrInvalidPixelScale     = errors.New("pixel scale must be in [0, 64]")
+	ErrBlendingPixelScale    = errors.New("pixel scale would blend source pixels")
+	ErrImageTooLarge         = errors.New("pixel art output exceeds 10000 cells per side")
+	ErrInvalidDitherStrength = errors.New("dither strength must be in [0.0, 1.0]")
+	ErrInvalidHistory        = errors.New("riemersma history must be in [0, 256]")
+	ErrInvalidLuminance      = errors.New("unknown luminance model")
+	ErrInvalidToneMapping    = errors.New("unknown tone mapping")
+	ErrInvalidResampling     = errors.New("unknown resampling filter")
+	ErrInvalidAggregation    = errors.New("unknown color aggregation")
+	ErrInvalidLevels         = errors.New("levels need 0 <= black point < white point <= 100")
+	ErrInvalidClahe          = errors.New("CLAHE needs tile in [0, 1024] and clip limit 0 or in [1.0, 100.0]")
 )
 
 func (c ConvertConfig) Validate() error {
-	if c.Resolution < 0.01 || c.Resolution > 1.0 {
+	if c.SizeMode == SizeScale && (c.Resolution < 0.01 || c.Resolution > 1.0) {
 		return fmt.Errorf("%w: %f", ErrInvalidResolution, c.Resolution)
 	}
+	if err := c.validateTransform(); err != nil {
+		return err
+	}
 	if c.Contrast < 0.1 || c.Contrast > 3.0 {
 		return fmt.Errorf("%w: %f", ErrInvalidContrast, c.Contrast)
 	}
 	if c.Brightness < 0.1 || c.Brightness > 3.0 {
 		return fmt.Errorf("%w: %f", ErrInvalidBrightness, c.Brightness)
 	}
+	if c.EdgeDetection && (c.EdgeThreshold < 0 || c.EdgeThreshold > 1.0) {
+		return fmt.Errorf("%w: %f", ErrInvalidEdgeThreshold, c.EdgeThreshold)
+	}
+	if c.Luminance < LuminanceRec601 || c.Luminance > LuminanceLab {
+		return fmt.Errorf("%w: %d", ErrInvalidLuminance, c.Luminance)
+	}
+	switch c.ToneMapping {
+	case ToneNone, ToneEqualize:
+	case ToneAutoLevels:
+		if c.BlackPoint < 0 || c.WhitePoint > 100 || c.BlackPoint >= c.WhitePoint {
+			return fmt.Errorf("%w: %f, %f", ErrInvalidLevels, c.BlackPoint, c.WhitePoint)
+		}
+	case ToneCLAHE:
+		if c.ClaheTile < 0 || c.ClaheTile > 1024 || (c.ClaheClip != 0 && (c.ClaheClip < 1 || c.ClaheClip > 100)) {
+			return fmt.Errorf("%w: tile %d, clip %f", ErrInvalidClahe, c.ClaheTile, c.ClaheClip)
+		}
+	default:
+		return fmt.Errorf("%w: %d", ErrInvalidToneMapping, c.ToneMapping)
+	}
+	if c.Resampling < ResampleLanczos || c.Resampling > ResampleArea {
+		return fmt.Errorf("%w: %d", ErrInvalidResampling, c.Resampling)
+	}
+	if c.ColorAggregation < ColorSampled || c.ColorAggregation > ColorDominant {
+		return fmt.Errorf("%w: %d", ErrInvalidAggregation, c.ColorAggregation)
+	}
+	for i, f := range c.Filters {
+		if err := f.Validate(); err != nil {
+			return fmt.Errorf("filter %d: %w", i, err)
+		}
+	}
+	if _, ok := c.Charset.entry(); !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownCharset, c.Charset)
+	}
+	if _, ok := c.Dithering.ditherer(); !ok {
+		return fmt.Errorf("%w: %d", ErrUnknownDithering, c.Dithering)
+	}
+	if strings.TrimSpace(c.CustomRamp) != "" {
+		if err := ValidateRamp(c.CustomRamp); err != nil {
+			return err
+		}
+	}
+	if c.DitherStrength < 0 || c.DitherStrength > 1.0 {
+		return fmt.Errorf("%w: %f", ErrInvalidDitherStrength, c.DitherStrength)
+	}
+	if c.RiemersmaHistory < 0 || c.RiemersmaHistory > maxRiemersmaHistory {
+		return fmt.Errorf("%w: %d", ErrInvalidHistory, c.RiemersmaHistory)
+	}
+	if c.ColorTolerance < 0 || c.ColorTolerance > 255 {
+		return fmt.Errorf("%w: %f", ErrInvalidTolerance, c.ColorTolerance)
+	}
+	if c.CellAspect != 0 && (c.CellAspect < 0.25 || c.CellAspect > 4.0) {
+		return fmt.Errorf("%w: %f", ErrInvalidCellAspect, c.CellAspect)
+	}
+	if c.TargetWidth < 0 || c.TargetWidth > maxTargetSize {
+		return fmt.Errorf("%w: width %d", ErrInvalidTarget, c.TargetWidth)
+	}
+	if c.TargetHeight < 0 || c.TargetHeight > maxTargetSize {
+		return fmt.Errorf("%w: height %d", ErrInvalidTarget, c.TargetHeight)
+	}
+
+	switch c.SizeMode {
+	case SizeScale:
+	case SizeWidth:
+		if c.TargetWidth == 0 {
+			return fmt.Errorf("%w: SizeWidth without TargetWidth", ErrMissingTarget)
+		}
+	case SizeHeight:
+		if c.TargetHeight == 0 {
+			return fmt.Errorf("%w: SizeHeight without TargetHeight", ErrMissingTarget)
+		}
+	case SizeFit:
+		if c.TargetWidth == 0 || c.TargetHeight == 0 {
+			return fmt.Errorf("%w: SizeFit needs TargetWidth and TargetHeight", ErrMissingTarget)
+		}
+	case SizePixelArt:
+		// Resolution is not used, the image decides the size
+		if err := c.validatePixelScale(); err != nil {
+			return err
+		}
+	default:
+		return fmt.Errorf("%w: %d", ErrInvalidSizeMode, c.SizeMode)
+	}
 	return nil
 }
 
-func (c ConvertConfig) ramps() (normal, inverted string) {
-	if strings.TrimSpace(c.CustomRamp) != "" {
-		r := c.CustomRamp
-		runes := []rune(r)
-		for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
-			runes[i], runes[j] = runes[j], runes[i]
+func (c ConvertConfig) ditherOptions() DitherOptions {
+	return DitherOptions{
+		Serpentine: c.Serpentine,
+		Strength:   c.DitherStrength,
+		History:    c.RiemersmaHistory,
+		Seed:       c.Seed,
+	}
+}
+
+func (c ConvertConfig) cellAspect() float64 {
+	if c.CellAspect == 0 {
+		return defaultCellAspect
+	}
+	return c.CellAspect
+}
+
+// outputSize returns the grid size in cells for an origW×origH image. The
+// image aspect ratio is kept, corrected for the cell aspect.
+func (c ConvertConfig) outputSize(origW, origH int) (int, int) {
+	aspect := c.cellAspect()
+	// height in rows per column of output
+	rowsPerCol := float64(origH) / float64(origW) / aspect
+
+	byWidth := func(w int) (int, int) {
+		return w, max(1, int(math.Round(float64(w)*rowsPerCol)))
+	}
+	byHeight := func(h int) (int, int) {
+		return max(1, int(math.Round(float64(h)/rowsPerCol))), h
+	}
+
+	switch c.SizeMode {
+	case SizeWidth:
+		return byWidth(c.TargetWidth)
+	case SizeHeight:
+		return byHeight(c.TargetHeight)
+	case SizeFit:
+		w, h := byWidth(c.TargetWidth)
+		if h > c.TargetHeight {
+			w, h = byHeight(c.TargetHeight)
 		}
-		return r, string(runes)
-	}
-
-	switch c.Charset {
-	case CharSetPhoto:
-		return asciiPhoto, asciiPhotoInv
-	case CharSetMinimal:
-		return asciiMinimal, asciiMinimalInv
-	case CharSetBlocks:
-		return asciiBlocks, asciiBlocksInv
-	case CharSetClassic:
-		fallthrough
+		return w, h
+	case SizePixelArt:
+		return c.pixelArtSize(origW, origH)
 	default:
-		return asciiClassic, asciiClassicInv
+		w := int(float64(origW) * c.Resolution)
+		h := int(float64(origH) * c.Resolution / aspect)
+		return w, h
+	}
+}
+
+// ramps returns the ramp to draw with, darkest first, and the one for
+// Inverted: the charset's hand-written one, or else the reverse.
+// CustomRamp wins over the charset; sub-pixel charsets fall back to the
+// classic ramp.
+func (c ConvertConfig) ramps() (normal, inverted string) {
+	e, _ := c.Charset.entry()
+	switch {
+	case strings.TrimSpace(c.CustomRamp) != "":
+		e = charsetEntry{ramp: c.CustomRamp}
+	case e.ramp == "":
+		e = charsetEntry{ramp: asciiClassic, inverted: asciiClassicInv}
 	}
+	normal = e.ramp
+	if e.inverted != "" {
+		return normal, e.inverted
+	}
+
+	runes := []rune(normal)
+	for i, j := 0, len(runes)-1; i < j; i, j = i+1, j-1 {
+		runes[i], runes[j] = runes[j], runes[i]
+	}
+	return normal, string(runes)
 }
 
 type AsciiResult struct {
 	Width, Height int
 	Chars         []rune
 	Colors        []color.NRGBA
-	Colored       bool
+	// Per-cell background colors, nil when the render sets none
+	Backgrounds []color.NRGBA
+	Colored     bool
+	// Color profile used by ToANSI
+	Profile ColorProfile
+	// Color merge tolerance used by ToANSI
+	Tolerance float64
+	// Per-cell opacity, nil when alpha was ignored. Transparent cells are
+	// blank and carry no color.
+	Alpha []uint8
+}
+
+// clear reports whether cell i is transparent.
+func (r *AsciiResult) clear(i int) bool {
+	return r.Alpha != nil && transparent(r.Alpha[i])
+}
+
+// cssColor formats c for CSS, with its alpha when the result keeps alpha.
+func (r *AsciiResult) cssColor(c color.NRGBA) string {
+	if r.Alpha != nil && c.A < 255 {
+		return fmt.Sprintf("rgba(%d,%d,%d,%.3f)", c.R, c.G, c.B, float64(c.A)/255.0)
+	}
+	return fmt.Sprintf("rgb(%d,%d,%d)", c.R, c.G, c.B)
+}
+
+// cellStyle returns the CSS color declarations for cell i.
+func (r *AsciiResult) cellStyle(i int) string {
+	style := "color:" + r.cssColor(r.Colors[i])
+	if r.Backgrounds != nil && r.Backgrounds[i].A > 0 {
+		style += ";background-color:" + r.cssColor(r.Backgrounds[i])
+	}
+	return style
 }
 
 func (r *AsciiResult) index(x, y int) int {
@@ -146,11 +431,14 @@ func (r *AsciiResult) ToMarkdownColored() string {
 			for x := 0; x < r.Width; x++ {
 				i := y*r.Width + x
 				ch := string(r.Chars[i])
-				col := r.Colors[i]
 				charEsc := escape(ch)
+				if r.clear(i) {
+					b.WriteString(charEsc)
+					continue
+				}
 				fmt.Fprintf(&b,
-					`<span style="color:rgb(%d,%d,%d)">%s</span>`,
-					col.R, col.G, col.B, charEsc,
+					`<span style="%s">%s</span>`,
+					r.cellStyle(i), charEsc,
 				)
 			}
 			b.WriteString("<br/>\n")
@@ -169,25 +457,13 @@ func (r *AsciiResult) ToMarkdownColored() string {
 	return b.String()
 }
 
+// ToANSI renders the result as colored terminal text for r.Profile. Escape
+// codes are only written when the color changes by more than r.Tolerance.
 func (r *AsciiResult) ToANSI() string {
-	if !r.Colored {
+	if !r.Colored || r.Profile == ColorProfileNone {
 		return r.ToPlainText()
 	}
-
-	var b strings.Builder
-
-	for y := 0; y < r.Height; y++ {
-		for x := 0; x < r.Width; x++ {
-			i := r.index(x, y)
-			ch := r.Chars[i]
-			col := r.Colors[i]
-			fmt.Fprintf(&b, "\x1b[38;2;%d;%d;%dm%s",
-				col.R, col.G, col.B, string(ch))
-		}
-		b.WriteByte('\n')
-	}
-	b.WriteString("\x1b[0m")
-	return b.String()
+	return encodeANSI(r)
 }
 
 func (r *AsciiResult) ToHTML() string {
@@ -237,10 +513,13 @@ pre {
 			for x := 0; x < r.Width; x++ {
 				i := r.index(x, y)
 				ch := string(r.Chars[i])
-				col := r.Colors[i]
+				if r.clear(i) {
+					b.WriteString(escape(ch))
+					continue
+				}
 				b.WriteString(fmt.Sprintf(
-					`<span style="color:rgb(%d,%d,%d)">%s</span>`,
-					col.R, col.G, col.B, escape(ch),
+					`<span style="%s">%s</span>`,
+					r.cellStyle(i), escape(ch),
 				))
 			}
 			b.WriteString("\n")
@@ -262,8 +541,8 @@ pre {
 	return b.String()
 }
 
-func getBrightness(r, g, b uint8) float64 {
-	return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+func getBrightness(r, g, b uint8, model Luminance) float64 {
+	return model.brightness(r, g, b)
 }
 
 func adjustPixel(value, contrast, brightness float64) float64 {
@@ -278,45 +557,72 @@ func adjustPixel(value, contrast, brightness float64) float64 {
 	return adjusted
 }
 
+// adjustColor prepares a resampled pixel: alpha handling, then contrast
+// and brightness.
+func adjustColor(c color.NRGBA, cfg ConvertConfig) color.NRGBA {
+	c = applyAlpha(c, cfg)
+
+	if cfg.GammaCorrect {
+		// rounded, so the neutral settings are an identity
+		adjust := func(v uint8) uint8 {
+			return clampByte(math.Round(adjustLinear(v, cfg.Contrast, cfg.Brightness)))
+		}
+		return color.NRGBA{R: adjust(c.R), G: adjust(c.G), B: adjust(c.B), A: c.A}
+	}
+
+	return color.NRGBA{
+		R: uint8(adjustPixel(float64(c.R), cfg.Contrast, cfg.Brightness)),
+		G: uint8(adjustPixel(float64(c.G), cfg.Contrast, cfg.Brightness)),
+		B: uint8(adjustPixel(float64(c.B), cfg.Contrast, cfg.Brightness)),
+		A: c.A,
+	}
+}
+
+// sample resizes img to w×h and returns the adjusted brightness and color of
+// every pixel, row-major.
+func sample(img image.Image, w, h int, cfg ConvertConfig) ([]float64, []color.NRGBA) {
+	rgbImg := resize(img, w, h, cfg.Resampling)
+	rgbImg = applyFilters(rgbImg, cfg.Filters)
+
+	grayscale := make([]float64, 0, w*h)
+	colors := make([]color.NRGBA, 0, w*h)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			c := adjustColor(rgbImg.NRGBAAt(x, y), cfg)
+			grayscale = append(grayscale, getBrightness(c.R, c.G, c.B, cfg.Luminance))
+			colors = append(colors, c)
+		}
+	}
+	applyTone(grayscale, w, h, cfg)
+	return grayscale, colors
+}
+
 func ConvertImage(img image.Image, cfg ConvertConfig) (*AsciiResult, error) {
 	if err := cfg.Validate(); err != nil {
 		return nil, err
 	}
 
+	img, err := cfg.transform(img)
+	if err != nil {
+		return nil, err
+	}
+
 	b := img.Bounds()
 	origW, origH := b.Dx(), b.Dy()
 
-	newW := int(float64(origW) * cfg.Resolution)
-	newH := int(float64(origH) * cfg.Resolution * 0.5) // chars are ~2:1 height:width
+	newW, newH := cfg.outputSize(origW, origH)
 
 	if newW < 1 || newH < 1 {
 		return nil, ErrImageTooSmall
 	}
 
-	// Lanczos resize (like Rust)
-	resized := imaging.Resize(img, newW, newH, imaging.Lanczos)
-	rgbImg := imaging.Clone(resized) // ensure concrete type
-
-	grayscale := make([]float64, 0, newW*newH)
-	colors := make([]color.NRGBA, 0, newW*newH)
-
-	for y := 0; y < newH; y++ {
-		for x := 0; x < newW; x++ {
-			c := color.NRGBAModel.Convert(rgbImg.At(x, y)).(color.NRGBA)
-
-			r := adjustPixel(float64(c.R), cfg.Contrast, cfg.Brightness)
-			g := adjustPixel(float64(c.G), cfg.Contrast, cfg.Brightness)
-			b := adjustPixel(float64(c.B), cfg.Contrast, cfg.Brightness)
-
-			gray := getBrightness(uint8(r), uint8(g), uint8(b))
-			grayscale = append(grayscale, gray)
-			colors = append(colors, color.NRGBA{
-				R: uint8(r),
-				G: uint8(g),
-				B: uint8(b),
-				A: 255,
-			})
+	if cfg.SizeMode == SizePixelArt {
+		if newW > maxTargetSize || newH > maxTargetSize {
+			return nil, fmt.Errorf("%w: %d×%d", ErrImageTooLarge, newW, newH)
 		}
+		img = cfg.pixelArtImage(img, newW, newH)
+		cfg = cfg.forPixelArt()
 	}
 
 	normalRamp, invertedRamp := cfg.ramps()
@@ -328,27 +634,97 @@ func ConvertImage(img image.Image, cfg ConvertConfig) (*AsciiResult, error) {
 		charsRamp = normalRamp
 	}
 	ramp := []rune(charsRamp)
+
+	// brightness each ramp glyph stands for, if not evenly spaced
+	var rampLevels []float64
+	custom := strings.TrimSpace(cfg.CustomRamp) != ""
+	if cfg.Calibrate || custom {
+		cal, ok, err := calibrateRamp([]rune(normalRamp), cfg.Inverted)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			ramp = cal.runes
+			if cfg.Calibrate {
+				rampLevels = cal.levels
+			}
+		}
+	}
 	levels := len(ramp)
 
-	cfg.Dithering.Apply(grayscale, newW, newH, levels)
+	var out *cells
+	// edges are measured on the undithered buffer
+	var source []float64
+
+	switch {
+	case cfg.Charset == CharSetHalfBlocks:
+		out = halfBlockCells(img, newW, newH, cfg)
+
+	case cfg.Charset == CharSetBraille:
+		out = brailleCells(img, newW, newH, cfg)
+
+	case cfg.Charset == CharSetQuadrants:
+		out = mosaicCells(img, newW, newH, 2, 2, quadrantGlyph, cfg)
+
+	case cfg.Charset == CharSetSextants:
+		out = mosaicCells(img, newW, newH, 2, 3, sextantGlyph, cfg)
 
-	asciiChars := make([]rune, len(grayscale))
-	for i, v := range grayscale {
-		idx := int(math.Round((v / 255.0) * float64(levels-1)))
-		if idx < 0 {
-			idx = 0
+	case cfg.Matching == MatchShapeSSE || cfg.Matching == MatchShapeSSIM:
+		out, err = shapeCells(img, newW, newH, ramp, cfg)
+		if err != nil {
+			return nil, err
 		}
-		if idx >= levels {
-			idx = levels - 1
+		source = out.gray
+
+	default:
+		out = newCells(newW*newH, false)
+		out.gray, out.fg = sample(img, newW, newH, cfg)
+
+		if cfg.EdgeDetection {
+			source = make([]float64, len(out.gray))
+			copy(source, out.gray)
 		}
-		asciiChars[i] = ramp[idx]
+
+		opts := cfg.ditherOptions()
+		opts.Levels = rampLevels
+		cfg.Dithering.ApplyOptions(out.gray, newW, newH, levels, opts)
+
+		ls := opts.levelSet(levels)
+		for i, v := range out.gray {
+			idx := ls.index(v, levels)
+			out.chars[i] = ramp[idx]
+		}
+	}
+
+	// two-tone cells are colored per sub-pixel already
+	if cfg.ColorAggregation != ColorSampled && out.bg == nil {
+		out.fg = aggregateColors(img, newW, newH, cfg)
+	}
+
+	// sub-pixel modes have no ramp glyphs to replace
+	if cfg.EdgeDetection && source != nil {
+		applyEdges(out.chars, source, newW, newH, cfg.EdgeThreshold)
+	}
+
+	var alpha []uint8
+	if cfg.Alpha != AlphaIgnore {
+		alpha = clearTransparent(out)
+	}
+
+	if cfg.Colored {
+		quantizeColors(out.fg, newW, newH, cfg)
+		quantizeColors(out.bg, newW, newH, cfg)
 	}
 
 	return &AsciiResult{
-		Width:   newW,
-		Height:  newH,
-		Chars:   asciiChars,
-		Colors:  colors,
-		Colored: cfg.Colored,
+		Width:       newW,
+		Height:      newH,
+		Chars:       out.chars,
+		Colors:      out.fg,
+		Backgrounds: out.bg,
+		Colored:     cfg.Colored,
+		Profile:     cfg.ColorProfile,
+		Tolerance:   cfg.ColorTolerance,
+		Alpha:       alpha,
 	}, nil
 }
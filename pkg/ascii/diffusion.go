@@ -0,0 +1,228 @@
+package ascii
+
+import (
+	"errors"
+	"fmt"
+)
+
+// DiffusionKernel describes how error diffusion spreads the quantization
+// error of a pixel. Weights[0] is the current row with the current pixel at
+// column Origin; the rows below follow. Each weight is divided by Divisor.
+// Entries at or left of the current pixel in row 0 must be zero since those
+// pixels are already final.
+type DiffusionKernel struct {
+	Weights [][]float64
+	Origin  int
+	Divisor float64
+}
+
+var ErrInvalidKernel = errors.New("invalid diffusion kernel")
+
+var (
+	KernelFloydSteinberg = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 7},
+			{3, 5, 1},
+		},
+		Origin:  1,
+		Divisor: 16,
+	}
+	KernelAtkinson = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 1, 1},
+			{1, 1, 1, 0},
+			{0, 1, 0, 0},
+		},
+		Origin:  1,
+		Divisor: 8,
+	}
+	KernelJarvisJudiceNinke = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 0, 7, 5},
+			{3, 5, 7, 5, 3},
+			{1, 3, 5, 3, 1},
+		},
+		Origin:  2,
+		Divisor: 48,
+	}
+	KernelStucki = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 0, 8, 4},
+			{2, 4, 8, 4, 2},
+			{1, 2, 4, 2, 1},
+		},
+		Origin:  2,
+		Divisor: 42,
+	}
+	KernelBurkes = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 0, 8, 4},
+			{2, 4, 8, 4, 2},
+		},
+		Origin:  2,
+		Divisor: 32,
+	}
+	KernelSierra = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 0, 5, 3},
+			{2, 4, 5, 4, 2},
+			{0, 2, 3, 2, 0},
+		},
+		Origin:  2,
+		Divisor: 32,
+	}
+	KernelTwoRowSierra = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 0, 4, 3},
+			{1, 2, 3, 2, 1},
+		},
+		Origin:  2,
+		Divisor: 16,
+	}
+	KernelSierraLite = DiffusionKernel{
+		Weights: [][]float64{
+			{0, 0, 2},
+			{1, 1, 0},
+		},
+		Origin:  1,
+		Divisor: 4,
+	}
+)
+
+// Validate checks that the kernel only pushes error forward.
+func (k DiffusionKernel) Validate() error {
+	if len(k.Weights) == 0 {
+		return fmt.Errorf("%w: no weights", ErrInvalidKernel)
+	}
+	if k.Divisor <= 0 {
+		return fmt.Errorf("%w: divisor must be positive", ErrInvalidKernel)
+	}
+	if k.Origin < 0 || k.Origin >= len(k.Weights[0]) {
+		return fmt.Errorf("%w: origin %d outside the first row", ErrInvalidKernel, k.Origin)
+	}
+	for x := 0; x <= k.Origin; x++ {
+		if k.Weights[0][x] != 0 {
+			return fmt.Errorf("%w: weight at or before the current pixel", ErrInvalidKernel)
+		}
+	}
+	return nil
+}
+
+type kernelDitherer struct {
+	name   string
+	kernel DiffusionKernel
+}
+
+func (d kernelDitherer) Name() string { return d.name }
+
+func (d kernelDitherer) Apply(gray []float64, width, height, levels int) {
+	diffuse(gray, width, height, levels, d.kernel, DefaultDitherOptions)
+}
+
+func (d kernelDitherer) ApplyOptions(gray []float64, width, height, levels int, opts DitherOptions) {
+	diffuse(gray, width, height, levels, d.kernel, opts)
+}
+
+func (d kernelDitherer) applyColor(buf [][3]float64, width, height int, snap func([3]float64) [3]float64, _ float64, opts DitherOptions) {
+	diffuseColor(buf, width, height, snap, d.kernel, opts)
+}
+
+// RegisterKernel registers error diffusion with a custom kernel under name.
+func RegisterKernel(name string, k DiffusionKernel) (DitheringStrategy, error) {
+	if err := k.Validate(); err != nil {
+		return 0, err
+	}
+	return RegisterDitherer(kernelDitherer{name: name, kernel: k})
+}
+
+// diffuse quantizes img to levels, scanning top to bottom, and spreads
+// each pixel's error over its neighbours per k. Rows run left to right, or
+// alternate direction with a mirrored kernel when serpentine. Error pushed
+// past the image edges is dropped.
+func diffuse(img []float64, width, height, levels int, k DiffusionKernel, opts DitherOptions) {
+	ls := opts.levelSet(levels)
+
+	for y := 0; y < height; y++ {
+		reverse := opts.Serpentine && y%2 == 1
+		for step := 0; step < width; step++ {
+			x := step
+			dir := 1
+			if reverse {
+				x = width - 1 - step
+				dir = -1
+			}
+			i := y*width + x
+
+			oldPixel := img[i]
+			newPixel := ls.nearest(oldPixel)
+			err := (oldPixel - newPixel) * opts.Strength
+
+			img[i] = newPixel
+
+			for ky, row := range k.Weights {
+				ny := y + ky
+				if ny >= height {
+					break
+				}
+				for kx, w := range row {
+					nx := x + (kx-k.Origin)*dir
+					if w == 0 || nx < 0 || nx >= width {
+						continue
+					}
+					img[ny*width+nx] += err * w / k.Divisor
+				}
+			}
+		}
+	}
+
+	for i := range img {
+		if img[i] < 0 {
+			img[i] = 0
+		} else if img[i] > 255 {
+			img[i] = 255
+		}
+	}
+}
+
+// diffuseColor is diffuse for RGB values snapped to a palette. Each channel
+// carries its own error.
+func diffuseColor(buf [][3]float64, width, height int, snap func([3]float64) [3]float64, k DiffusionKernel, opts DitherOptions) {
+	for y := 0; y < height; y++ {
+		reverse := opts.Serpentine && y%2 == 1
+		for step := 0; step < width; step++ {
+			x := step
+			dir := 1
+			if reverse {
+				x = width - 1 - step
+				dir = -1
+			}
+			i := y*width + x
+
+			old := buf[i]
+			q := snap(old)
+			buf[i] = q
+
+			var err [3]float64
+			for ch := range err {
+				err[ch] = (old[ch] - q[ch]) * opts.Strength
+			}
+
+			for ky, row := range k.Weights {
+				ny := y + ky
+				if ny >= height {
+					break
+				}
+				for kx, w := range row {
+					nx := x + (kx-k.Origin)*dir
+					if w == 0 || nx < 0 || nx >= width {
+						continue
+					}
+					n := ny*width + nx
+					for ch := range err {
+						buf[n][ch] += err[ch] * w / k.Divisor
+					}
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,210 @@
+package ascii
+
+import (
+	"image/color"
+	"math"
+	"testing"
+)
+
+// The loops below are the hand-written error diffusion the kernel engine
+// replaced, kept as the reference its output must match.
+
+func baselineFloydSteinberg(img []float64, width, height, levels int) {
+	scale := 255.0 / float64(levels-1)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+
+			oldPixel := img[i]
+			newPixel := math.Round(oldPixel/scale) * scale
+			err := oldPixel - newPixel
+
+			img[i] = newPixel
+
+			if x+1 < width {
+				img[y*width+(x+1)] += err * 7.0 / 16.0
+			}
+			if y+1 < height {
+				if x > 0 {
+					img[(y+1)*width+(x-1)] += err * 3.0 / 16.0
+				}
+				img[(y+1)*width+x] += err * 5.0 / 16.0
+				if x+1 < width {
+					img[(y+1)*width+(x+1)] += err * 1.0 / 16.0
+				}
+			}
+		}
+	}
+
+	for i := range img {
+		if img[i] < 0 {
+			img[i] = 0
+		} else if img[i] > 255 {
+			img[i] = 255
+		}
+	}
+}
+
+func baselineAtkinson(img []float64, width, height, levels int) {
+	scale := 255.0 / float64(levels-1)
+
+	for y := 0; y < height; y++ {
+		for x := 0; x < width; x++ {
+			i := y*width + x
+
+			oldPixel := img[i]
+			newPixel := math.Round(oldPixel/scale) * scale
+			err := oldPixel - newPixel
+			errFrac := err / 8.0
+
+			img[i] = newPixel
+
+			if x+1 < width {
+				img[y*width+(x+1)] += errFrac
+			}
+			if x+2 < width {
+				img[y*width+(x+2)] += errFrac
+			}
+			if y+1 < height {
+				if x > 0 {
+					img[(y+1)*width+(x-1)] += errFrac
+				}
+				img[(y+1)*width+x] += errFrac
+				if x+1 < width {
+					img[(y+1)*width+(x+1)] += errFrac
+				}
+			}
+			if y+2 < height {
+				img[(y+2)*width+x] += errFrac
+			}
+		}
+	}
+
+	for i := range img {
+		if img[i] < 0 {
+			img[i] = 0
+		} else if img[i] > 255 {
+			img[i] = 255
+		}
+	}
+}
+
+// diffusionTap is one neighbour of baselineColor: its offset and weight.
+type diffusionTap struct {
+	dx, dy int
+	weight float64
+}
+
+var (
+	floydSteinbergTaps = []diffusionTap{{1, 0, 7.0 / 16.0}, {-1, 1, 3.0 / 16.0}, {0, 1, 5.0 / 16.0}, {1, 1, 1.0 / 16.0}}
+	atkinsonTaps       = []diffusionTap{{1, 0, 1.0 / 8.0}, {2, 0, 1.0 / 8.0}, {-1, 1, 1.0 / 8.0}, {0, 1, 1.0 / 8.0}, {1, 1, 1.0 / 8.0}, {0, 2, 1.0 / 8.0}}
+)
+
+// baselineColor is the color dithering quantizeColors used to hard-code,
+// with the neighbours passed in.
+func baselineColor(colors []color.NRGBA, w, h int, p ColorProfile, taps []diffusionTap) {
+	buf := make([][3]float64, len(colors))
+	for i, c := range colors {
+		buf[i] = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	}
+
+	spread := func(x, y int, err [3]float64, weight float64) {
+		if x < 0 || x >= w || y >= h {
+			return
+		}
+		i := y*w + x
+		for ch := range err {
+			buf[i][ch] += err[ch] * weight
+		}
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			old := color.NRGBA{
+				R: clampByte(buf[i][0]),
+				G: clampByte(buf[i][1]),
+				B: clampByte(buf[i][2]),
+				A: colors[i].A,
+			}
+			q := p.quantize(old)
+			q.A = old.A
+			colors[i] = q
+
+			err := [3]float64{
+				buf[i][0] - float64(q.R),
+				buf[i][1] - float64(q.G),
+				buf[i][2] - float64(q.B),
+			}
+			for _, t := range taps {
+				spread(x+t.dx, y+t.dy, err, t.weight)
+			}
+		}
+	}
+}
+
+// grayGradient is a w×h ramp from black to white along x, shifted a little
+// per row so the rows do not dither alike.
+func grayGradient(w, h int) []float64 {
+	gray := make([]float64, w*h)
+	for y := range h {
+		for x := range w {
+			gray[y*w+x] = math.Mod(float64(x)*255/float64(w-1)+float64(y)*3.7, 256)
+		}
+	}
+	return gray
+}
+
+func TestDiffusionMatchesBaselineGray(t *testing.T) {
+	const w, h = 48, 16
+	tests := []struct {
+		s    DitheringStrategy
+		base func([]float64, int, int, int)
+	}{
+		{DitheringFloydSteinberg, baselineFloydSteinberg},
+		{DitheringAtkinson, baselineAtkinson},
+	}
+	for _, tt := range tests {
+		for _, levels := range []int{2, 5, 10, 70} {
+			want, got := grayGradient(w, h), grayGradient(w, h)
+			tt.base(want, w, h, levels)
+			tt.s.Apply(got, w, h, levels)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("%s, %d levels: pixel %d is %v, baseline %v", tt.s.Name(), levels, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
+
+func TestDiffusionMatchesBaselineColor(t *testing.T) {
+	const w, h = 48, 16
+	tests := []struct {
+		s    DitheringStrategy
+		taps []diffusionTap
+	}{
+		{DitheringFloydSteinberg, floydSteinbergTaps},
+		{DitheringAtkinson, atkinsonTaps},
+	}
+	for _, tt := range tests {
+		for _, p := range []ColorProfile{ColorProfileANSI16, ColorProfileANSI256} {
+			src := gradient(w, h)
+			want := make([]color.NRGBA, w*h)
+			for i := range want {
+				want[i] = src.NRGBAAt(i%w, i/w)
+			}
+			got := append([]color.NRGBA(nil), want...)
+
+			baselineColor(want, w, h, p, tt.taps)
+			cfg := ConvertConfig{ColorProfile: p, ColorDither: true, Dithering: tt.s, DitherStrength: 1}
+			quantizeColors(got, w, h, cfg)
+			for i := range want {
+				if got[i] != want[i] {
+					t.Fatalf("%s, %s: cell %d is %v, baseline %v", tt.s.Name(), p, i, got[i], want[i])
+				}
+			}
+		}
+	}
+}
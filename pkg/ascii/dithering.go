@@ -1,7 +1,9 @@
 package ascii
 
 import (
+	"errors"
 	"math"
+	"sort"
 )
 
 type DitheringStrategy int
@@ -14,232 +16,215 @@ const (
 	DitheringOrdered2x2
 	DitheringOrdered4x4
 	DitheringThreshold
+	DitheringJarvisJudiceNinke
+	DitheringStucki
+	DitheringBurkes
+	DitheringSierra
+	DitheringTwoRowSierra
+	DitheringSierraLite
+	DitheringOrdered8x8
+	DitheringOrdered16x16
+	DitheringBlueNoise
+	DitheringWhiteNoise
+	DitheringInterleavedGradient
 )
 
-func (d DitheringStrategy) Apply(gray []float64, width, height, levels int) {
-	switch d {
-	case DitheringNone:
-		return
-	case DitheringFloydSteinberg:
-		floydSteinberg(gray, width, height, levels)
-	case DitheringAtkinson:
-		atkinson(gray, width, height, levels)
-	case DitheringRiemersma:
-		riemersma(gray, width, height, levels)
-	case DitheringOrdered2x2:
-		ordered2x2(gray, width, height, levels)
-	case DitheringOrdered4x4:
-		ordered4x4(gray, width, height, levels)
-	case DitheringThreshold:
-		thresholdDither(gray, width, height, levels)
-	}
+// Ditherer quantizes a row-major grayscale buffer (0–255) in place to
+// levels evenly spaced values.
+type Ditherer interface {
+	Name() string
+	Apply(gray []float64, width, height, levels int)
 }
 
-func floydSteinberg(img []float64, width, height, levels int) {
-	scale := 255.0 / float64(levels-1)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			i := y*width + x
-
-			oldPixel := img[i]
-			newPixel := math.Round(oldPixel/scale) * scale
-			err := oldPixel - newPixel
-
-			img[i] = newPixel
-
-			if x+1 < width {
-				img[y*width+(x+1)] += err * 7.0 / 16.0
-			}
-			if y+1 < height {
-				if x > 0 {
-					img[(y+1)*width+(x-1)] += err * 3.0 / 16.0
-				}
-				img[(y+1)*width+x] += err * 5.0 / 16.0
-				if x+1 < width {
-					img[(y+1)*width+(x+1)] += err * 1.0 / 16.0
-				}
-			}
-		}
-	}
+// DitherOptions tune how a strategy runs. Ditherers that only implement
+// Ditherer ignore them.
+type DitherOptions struct {
+	// Alternate the scan direction every row (error diffusion)
+	Serpentine bool
+	// Fraction of the quantization error that is propagated (0–1). Ordered
+	// strategies scale their threshold offsets by it instead.
+	Strength float64
+	// Number of past errors Riemersma dithering weighs (0 means 16)
+	History int
+	// Seed of the noise strategies, blue noise included
+	Seed uint64
+	// Explicit output values (0–255, ascending) replacing the evenly spaced
+	// levels, e.g. for a ramp calibrated to glyph coverage. Plain Ditherers
+	// still get the evenly spaced count.
+	Levels []float64
+}
 
-	for i := range img {
-		if img[i] < 0 {
-			img[i] = 0
-		} else if img[i] > 255 {
-			img[i] = 255
-		}
-	}
+// DefaultDitherOptions is what Apply runs with.
+var DefaultDitherOptions = DitherOptions{Strength: 1.0}
+
+// TunableDitherer is a Ditherer that honours DitherOptions.
+type TunableDitherer interface {
+	Ditherer
+	ApplyOptions(gray []float64, width, height, levels int, opts DitherOptions)
 }
 
-func atkinson(img []float64, width, height, levels int) {
-	scale := 255.0 / float64(levels-1)
-
-	for y := 0; y < height; y++ {
-		for x := 0; x < width; x++ {
-			i := y*width + x
-
-			oldPixel := img[i]
-			newPixel := math.Round(oldPixel/scale) * scale
-			err := oldPixel - newPixel
-			errFrac := err / 8.0
-
-			img[i] = newPixel
-
-			if x+1 < width {
-				img[y*width+(x+1)] += errFrac
-			}
-			if x+2 < width {
-				img[y*width+(x+2)] += errFrac
-			}
-			if y+1 < height {
-				if x > 0 {
-					img[(y+1)*width+(x-1)] += errFrac
-				}
-				img[(y+1)*width+x] += errFrac
-				if x+1 < width {
-					img[(y+1)*width+(x+1)] += errFrac
-				}
-			}
-			if y+2 < height {
-				img[(y+2)*width+x] += errFrac
-			}
-		}
-	}
+// colorDitherer is implemented by built-in strategies that can also dither
+// RGB colors onto a palette. snap returns the palette color for a value;
+// spread is the rough distance between palette entries per channel.
+type colorDitherer interface {
+	applyColor(buf [][3]float64, width, height int, snap func([3]float64) [3]float64, spread float64, opts DitherOptions)
+}
 
-	for i := range img {
-		if img[i] < 0 {
-			img[i] = 0
-		} else if img[i] > 255 {
-			img[i] = 255
-		}
-	}
+var (
+	ErrDuplicateDitherer = errors.New("ditherer already registered")
+	ErrUnknownDithering  = errors.New("unknown dithering strategy")
+)
+
+var ditherers = newRegistry[DitheringStrategy](Ditherer.Name)
+
+// ditherFunc adapts a plain function to TunableDitherer.
+type ditherFunc struct {
+	name string
+	fn   func(gray []float64, width, height, levels int, opts DitherOptions)
+}
+
+func (d ditherFunc) Name() string { return d.name }
+
+func (d ditherFunc) Apply(gray []float64, width, height, levels int) {
+	d.fn(gray, width, height, levels, DefaultDitherOptions)
+}
+
+func (d ditherFunc) ApplyOptions(gray []float64, width, height, levels int, opts DitherOptions) {
+	d.fn(gray, width, height, levels, opts)
+}
+
+func init() {
+	registerDitherer(DitheringNone, ditherFunc{"None", func([]float64, int, int, int, DitherOptions) {}})
+	registerDitherer(DitheringFloydSteinberg, kernelDitherer{"Floyd-Steinberg", KernelFloydSteinberg})
+	registerDitherer(DitheringAtkinson, kernelDitherer{"Atkinson", KernelAtkinson})
+	registerDitherer(DitheringRiemersma, riemersmaDitherer{})
+	registerDitherer(DitheringOrdered2x2, newOrderedDitherer("Ordered 2x2", bayer2Edges))
+	registerDitherer(DitheringOrdered4x4, newOrderedDitherer("Ordered 4x4", bayer(4)))
+	registerDitherer(DitheringThreshold, ditherFunc{"Threshold", thresholdDither})
+	registerDitherer(DitheringWhiteNoise, noiseDitherer{"White noise", whiteNoise})
+	registerDitherer(DitheringInterleavedGradient, noiseDitherer{"Interleaved gradient", interleavedGradientNoise})
+	registerDitherer(DitheringJarvisJudiceNinke, kernelDitherer{"Jarvis-Judice-Ninke", KernelJarvisJudiceNinke})
+	registerDitherer(DitheringStucki, kernelDitherer{"Stucki", KernelStucki})
+	registerDitherer(DitheringBurkes, kernelDitherer{"Burkes", KernelBurkes})
+	registerDitherer(DitheringSierra, kernelDitherer{"Sierra", KernelSierra})
+	registerDitherer(DitheringTwoRowSierra, kernelDitherer{"Two-Row Sierra", KernelTwoRowSierra})
+	registerDitherer(DitheringSierraLite, kernelDitherer{"Sierra Lite", KernelSierraLite})
+	registerDitherer(DitheringOrdered8x8, newOrderedDitherer("Ordered 8x8", bayer(8)))
+	registerDitherer(DitheringOrdered16x16, newOrderedDitherer("Ordered 16x16", bayer(16)))
+	registerDitherer(DitheringBlueNoise, noiseDitherer{"Blue noise", blueNoise})
+}
+
+func registerDitherer(s DitheringStrategy, d Ditherer) {
+	ditherers.add(s, d)
+}
+
+// RegisterDitherer makes d available to ConvertImage and returns the
+// strategy that selects it. Names must be unique.
+func RegisterDitherer(d Ditherer) (DitheringStrategy, error) {
+	return ditherers.register(d, ErrDuplicateDitherer)
 }
 
-func riemersma(img []float64, width, height, levels int) {
-	scale := 255.0 / float64(levels-1)
-	total := width * height
-	visited := make([]bool, total)
-	var err float64
-
-	directions := [][2]int{
-		{0, 1},
-		{1, 0},
-		{0, -1},
-		{-1, 0},
-		{1, 1},
-		{1, -1},
-		{-1, -1},
-		{-1, 1},
+// DitheringStrategies lists every registered strategy in registration
+// order.
+func DitheringStrategies() []DitheringStrategy {
+	return ditherers.ids()
+}
+
+// DitheringByName looks a strategy up by its ditherer's name.
+func DitheringByName(name string) (DitheringStrategy, bool) {
+	return ditherers.byName(name)
+}
+
+func (d DitheringStrategy) ditherer() (Ditherer, bool) {
+	return ditherers.get(d)
+}
+
+// Name returns the registered name of the strategy, or "?" if unknown.
+func (d DitheringStrategy) Name() string {
+	if impl, ok := d.ditherer(); ok {
+		return impl.Name()
 	}
+	return "?"
+}
 
-	row, col := 0, 0
-	visited[0] = true
-
-	for range img {
-		idx := row*width + col
-		oldPixel := img[idx] + err
-		newPixel := math.Round(oldPixel/scale) * scale
-		err = oldPixel - newPixel
-		img[idx] = newPixel
-
-		for _, d := range directions {
-			nr := row + d[0]
-			nc := col + d[1]
-			if nr >= 0 && nr < height && nc >= 0 && nc < width {
-				nIdx := nr*width + nc
-				if !visited[nIdx] {
-					row, col = nr, nc
-					visited[nIdx] = true
-					break
-				}
-			}
-		}
+// Apply runs the registered ditherer. Unknown strategies leave gray as is.
+func (d DitheringStrategy) Apply(gray []float64, width, height, levels int) {
+	d.ApplyOptions(gray, width, height, levels, DefaultDitherOptions)
+}
+
+// ApplyOptions is Apply with tuning for ditherers that support it.
+func (d DitheringStrategy) ApplyOptions(gray []float64, width, height, levels int, opts DitherOptions) {
+	impl, ok := d.ditherer()
+	if !ok {
+		return
+	}
+	if t, ok := impl.(TunableDitherer); ok {
+		t.ApplyOptions(gray, width, height, levels, opts)
+		return
 	}
+	impl.Apply(gray, width, height, levels)
+}
 
+func thresholdDither(img []float64, w, h, levels int, opts DitherOptions) {
+	ls := opts.levelSet(levels)
 	for i := range img {
-		if img[i] < 0 {
-			img[i] = 0
-		} else if img[i] > 255 {
-			img[i] = 255
-		}
+		img[i] = ls.nearest(img[i])
 	}
 }
 
-func ordered2x2(img []float64, w, h, levels int) {
-	matrix := [2][2]float64{
-		{0.0 / 4.0, 2.0 / 4.0},
-		{3.0 / 4.0, 1.0 / 4.0},
-	}
+// levelSet is the set of values a ditherer quantizes to: levels evenly
+// spaced over 0–255 or explicit ascending values.
+type levelSet struct {
+	scale  float64
+	values []float64
+}
 
-	scale := 255.0 / float64(levels-1)
-
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			i := y*w + x
-
-			old := img[i]
-			threshold := (matrix[y%2][x%2] - 0.5) * scale
-			val := old + threshold
-
-			newVal := math.Round(val/scale) * scale
-			if newVal < 0 {
-				newVal = 0
-			}
-			if newVal > 255 {
-				newVal = 255
-			}
-			img[i] = newVal
-		}
+func (o DitherOptions) levelSet(levels int) levelSet {
+	if len(o.Levels) >= 2 {
+		return levelSet{values: o.Levels}
 	}
+	return levelSet{scale: 255.0 / float64(levels-1)}
 }
 
-func ordered4x4(img []float64, w, h, levels int) {
-	matrix := [4][4]float64{
-		{0, 8, 2, 10},
-		{12, 4, 14, 6},
-		{3, 11, 1, 9},
-		{15, 7, 13, 5},
-	}
-	for y := range matrix {
-		for x := range matrix[y] {
-			matrix[y][x] = (matrix[y][x] + 0.5) / 16.0
-		}
-	}
+// nearest returns the level closest to v.
+func (l levelSet) nearest(v float64) float64 {
+	return l.offset(v, 0)
+}
 
-	scale := 255.0 / float64(levels-1)
-
-	for y := 0; y < h; y++ {
-		for x := 0; x < w; x++ {
-			i := y*w + x
-			old := img[i]
-
-			threshold := (matrix[y%4][x%4] - 0.5) * scale
-			val := old + threshold
-
-			newVal := math.Round(val/scale) * scale
-			if newVal < 0 {
-				newVal = 0
-			}
-			if newVal > 255 {
-				newVal = 255
-			}
-			img[i] = newVal
-		}
+// index returns the position of the level closest to v among n levels.
+func (l levelSet) index(v float64, n int) int {
+	var idx int
+	if l.values == nil {
+		idx = int(math.Round(v / l.scale))
+	} else {
+		q := l.nearest(v)
+		idx = sort.SearchFloat64s(l.values, q)
 	}
+	return max(0, min(n-1, idx))
 }
 
-func thresholdDither(img []float64, w, h, levels int) {
-	scale := 255.0 / float64(levels-1)
-	for i := range img {
-		val := img[i]
-		newVal := math.Round(val/scale) * scale
-		if newVal < 0 {
-			newVal = 0
-		}
-		if newVal > 255 {
-			newVal = 255
-		}
-		img[i] = newVal
+// offset quantizes v after shifting it by o (-0.5–0.5) times the step
+// between the two levels around it.
+func (l levelSet) offset(v, o float64) float64 {
+	if l.values == nil {
+		return math.Round((v+o*l.scale)/l.scale) * l.scale
+	}
+
+	vals := l.values
+	if v <= vals[0] {
+		return vals[0]
+	}
+	if v >= vals[len(vals)-1] {
+		return vals[len(vals)-1]
+	}
+	hi := sort.SearchFloat64s(vals, v)
+	lo := hi - 1
+	if vals[hi] == v {
+		return v
+	}
+	f := (v - vals[lo]) / (vals[hi] - vals[lo])
+	if f+o >= 0.5 {
+		return vals[hi]
 	}
+	return vals[lo]
 }
@@ -0,0 +1,114 @@
+package ascii
+
+import (
+	"errors"
+	"math"
+	"slices"
+	"testing"
+)
+
+// A strength of 0 propagates no error, so every strategy falls back to
+// plain thresholding.
+func TestZeroDitherStrengthMeansNone(t *testing.T) {
+	convert := func(d DitheringStrategy, strength float64) []rune {
+		cfg := ConvertConfig{Resolution: 1, Contrast: 1, Brightness: 1, Dithering: d, DitherStrength: strength}
+		res, err := ConvertImage(gradient(48, 24), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return res.Chars
+	}
+
+	plain := convert(DitheringThreshold, 1)
+	for _, d := range []DitheringStrategy{DitheringFloydSteinberg, DitheringAtkinson, DitheringOrdered4x4} {
+		if !slices.Equal(convert(d, 0), plain) {
+			t.Errorf("%s: strength 0 differs from Threshold", d.Name())
+		}
+		if slices.Equal(convert(d, 1), plain) {
+			t.Errorf("%s: strength 1 does not dither", d.Name())
+		}
+	}
+}
+
+// baselineOrdered2x2 is Ordered 2x2 as it was before threshold maps.
+func baselineOrdered2x2(img []float64, w, h, levels int) {
+	matrix := [2][2]float64{
+		{0.0 / 4.0, 2.0 / 4.0},
+		{3.0 / 4.0, 1.0 / 4.0},
+	}
+
+	scale := 255.0 / float64(levels-1)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+
+			old := img[i]
+			threshold := (matrix[y%2][x%2] - 0.5) * scale
+			val := old + threshold
+
+			newVal := math.Round(val/scale) * scale
+			if newVal < 0 {
+				newVal = 0
+			}
+			if newVal > 255 {
+				newVal = 255
+			}
+			img[i] = newVal
+		}
+	}
+}
+
+func TestOrdered2x2KeepsItsThresholds(t *testing.T) {
+	const w, h = 48, 16
+	for _, levels := range []int{2, 5, 10} {
+		want, got := grayGradient(w, h), grayGradient(w, h)
+		baselineOrdered2x2(want, w, h, levels)
+		DitheringOrdered2x2.Apply(got, w, h, levels)
+		for i := range want {
+			if got[i] != want[i] {
+				t.Fatalf("%d levels: pixel %d is %v, baseline %v", levels, i, got[i], want[i])
+			}
+		}
+	}
+}
+
+func TestValidateRejectsUnregistered(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Dithering = DitheringStrategy(customIDBase + 999)
+	if err := cfg.Validate(); !errors.Is(err, ErrUnknownDithering) {
+		t.Errorf("unregistered dithering: got %v, want ErrUnknownDithering", err)
+	}
+
+	cfg = DefaultConfig()
+	cfg.Charset = CharSet(customIDBase + 999)
+	if err := cfg.Validate(); !errors.Is(err, ErrUnknownCharset) {
+		t.Errorf("unregistered charset: got %v, want ErrUnknownCharset", err)
+	}
+}
+
+func TestRegisterRejectsDuplicateNames(t *testing.T) {
+	if _, err := RegisterDitherer(kernelDitherer{"Atkinson", KernelAtkinson}); !errors.Is(err, ErrDuplicateDitherer) {
+		t.Errorf("duplicate ditherer: got %v, want ErrDuplicateDitherer", err)
+	}
+	if _, err := RegisterCharset("Classic", "ab"); !errors.Is(err, ErrDuplicateCharset) {
+		t.Errorf("duplicate charset: got %v, want ErrDuplicateCharset", err)
+	}
+}
+
+func TestBlueNoiseHonoursSeed(t *testing.T) {
+	const w, h = 48, 16
+	run := func(seed uint64) []float64 {
+		gray := grayGradient(w, h)
+		opts := DefaultDitherOptions
+		opts.Seed = seed
+		DitheringBlueNoise.ApplyOptions(gray, w, h, 5, opts)
+		return gray
+	}
+	if !slices.Equal(run(7), run(7)) {
+		t.Error("the same seed gave different output")
+	}
+	if slices.Equal(run(7), run(8)) {
+		t.Error("different seeds gave the same output")
+	}
+}
@@ -0,0 +1,80 @@
+package ascii
+
+import "math"
+
+// Strongest possible response of a single Sobel axis on a 0–255 buffer.
+const sobelMax = 4 * 255.0
+
+// sobel computes the horizontal and vertical gradients of the grayscale
+// buffer. Pixels outside the image are clamped to the nearest edge.
+func sobel(gray []float64, w, h int) (gx, gy []float64) {
+	gx = make([]float64, len(gray))
+	gy = make([]float64, len(gray))
+
+	at := func(x, y int) float64 {
+		if x < 0 {
+			x = 0
+		} else if x >= w {
+			x = w - 1
+		}
+		if y < 0 {
+			y = 0
+		} else if y >= h {
+			y = h - 1
+		}
+		return gray[y*w+x]
+	}
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			tl, t, tr := at(x-1, y-1), at(x, y-1), at(x+1, y-1)
+			l, r := at(x-1, y), at(x+1, y)
+			bl, b, br := at(x-1, y+1), at(x, y+1), at(x+1, y+1)
+
+			i := y*w + x
+			gx[i] = (tr + 2*r + br) - (tl + 2*l + bl)
+			gy[i] = (bl + 2*b + br) - (tl + 2*t + tr)
+		}
+	}
+	return gx, gy
+}
+
+// edgeGlyph picks a line character running perpendicular to the gradient.
+// Image y grows downwards, so a gradient pointing down-right means the edge
+// runs bottom-left to top-right.
+func edgeGlyph(gx, gy float64) rune {
+	angle := math.Atan2(gy, gx) * 180 / math.Pi
+	if angle < 0 {
+		angle += 180
+	}
+
+	switch {
+	case angle < 22.5 || angle >= 157.5:
+		return '|'
+	case angle < 67.5:
+		return '/'
+	case angle < 112.5:
+		// brighter below: the stroke sits on the lower boundary of the cell
+		if gy > 0 {
+			return '_'
+		}
+		return '-'
+	default:
+		return '\\'
+	}
+}
+
+// applyEdges overwrites chars with direction glyphs wherever the gradient
+// magnitude of gray passes threshold (0–1, relative to sobelMax).
+func applyEdges(chars []rune, gray []float64, w, h int, threshold float64) {
+	gx, gy := sobel(gray, w, h)
+	limit := threshold * sobelMax
+
+	for i := range chars {
+		mag := math.Hypot(gx[i], gy[i])
+		if mag < limit || mag == 0 {
+			continue
+		}
+		chars[i] = edgeGlyph(gx[i], gy[i])
+	}
+}
@@ -0,0 +1,10 @@
+package ascii
+
+// enumValues lists the constants 0 to count-1 of an iota enum.
+func enumValues[T ~int](count T) []T {
+	all := make([]T, count)
+	for i := range all {
+		all[i] = T(i)
+	}
+	return all
+}
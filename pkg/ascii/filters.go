@@ -0,0 +1,330 @@
+package ascii
+
+import (
+	"errors"
+	"fmt"
+	"image"
+	"image/color"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+type FilterKind int
+
+const (
+	FilterUnsharp     FilterKind = iota // sharpen: Amount strength, Radius blur sigma
+	FilterBlur                          // gaussian blur: Radius sigma
+	FilterEdgeEnhance                   // 3×3 edge boost: Amount strength
+	FilterPosterize                     // Amount levels per channel
+	FilterEmboss                        // relief over the image: Amount strength
+	FilterBilateral                     // edge-preserving smoothing: Radius spatial sigma, Amount range sigma
+	FilterVignette                      // darken towards the corners: Amount strength, Radius where it starts (0–1)
+	FilterGamma                         // Amount gamma, above 1 brightens
+	FilterSaturation                    // Amount factor, 0 is grayscale
+	FilterHue                           // rotate hue by Amount degrees
+
+	filterKindCount
+)
+
+// Filter is one step of the pre-processing pipeline. What Amount and Radius
+// mean depends on Kind; parameters a kind does not use are ignored.
+type Filter struct {
+	Kind   FilterKind
+	Amount float64
+	Radius float64
+}
+
+var ErrInvalidFilter = errors.New("invalid filter")
+
+// FilterKinds lists every filter kind in pipeline-panel order.
+func FilterKinds() []FilterKind {
+	return enumValues(filterKindCount)
+}
+
+func (k FilterKind) String() string {
+	switch k {
+	case FilterUnsharp:
+		return "Unsharp"
+	case FilterBlur:
+		return "Blur"
+	case FilterEdgeEnhance:
+		return "Edge enhance"
+	case FilterPosterize:
+		return "Posterize"
+	case FilterEmboss:
+		return "Emboss"
+	case FilterBilateral:
+		return "Bilateral"
+	case FilterVignette:
+		return "Vignette"
+	case FilterGamma:
+		return "Gamma"
+	case FilterSaturation:
+		return "Saturation"
+	case FilterHue:
+		return "Hue"
+	default:
+		return "?"
+	}
+}
+
+// Limits returns the valid Amount and Radius ranges of the kind. A kind
+// that ignores a parameter reports 0, 0 for it.
+func (k FilterKind) Limits() (amountMin, amountMax, radiusMin, radiusMax float64) {
+	switch k {
+	case FilterUnsharp:
+		return 0, 10, 0.1, 20
+	case FilterBlur:
+		return 0, 0, 0.1, 20
+	case FilterEdgeEnhance, FilterEmboss:
+		return 0, 10, 0, 0
+	case FilterPosterize:
+		return 2, 256, 0, 0
+	case FilterBilateral:
+		return 1, 255, 0.1, 10
+	case FilterVignette:
+		return 0, 1, 0, 0.95
+	case FilterGamma:
+		return 0.1, 10, 0, 0
+	case FilterSaturation:
+		return 0, 5, 0, 0
+	case FilterHue:
+		return -360, 360, 0, 0
+	default:
+		return 0, 0, 0, 0
+	}
+}
+
+// DefaultFilter returns a filter of the given kind with a moderate setting.
+func DefaultFilter(k FilterKind) Filter {
+	f := Filter{Kind: k}
+	switch k {
+	case FilterUnsharp:
+		f.Amount, f.Radius = 1, 1
+	case FilterBlur:
+		f.Radius = 1
+	case FilterEdgeEnhance, FilterEmboss:
+		f.Amount = 1
+	case FilterPosterize:
+		f.Amount = 4
+	case FilterBilateral:
+		f.Amount, f.Radius = 30, 2
+	case FilterVignette:
+		f.Amount, f.Radius = 0.5, 0.5
+	case FilterGamma:
+		f.Amount = 1
+	case FilterSaturation:
+		f.Amount = 1.5
+	case FilterHue:
+		f.Amount = 30
+	}
+	return f
+}
+
+// Validate checks the kind and the parameters it uses.
+func (f Filter) Validate() error {
+	if f.Kind < 0 || f.Kind >= filterKindCount {
+		return fmt.Errorf("%w: unknown kind %d", ErrInvalidFilter, f.Kind)
+	}
+	amin, amax, rmin, rmax := f.Kind.Limits()
+	if amax > amin && (f.Amount < amin || f.Amount > amax || math.IsNaN(f.Amount)) {
+		return fmt.Errorf("%w: %s amount %f not in [%g, %g]", ErrInvalidFilter, f.Kind, f.Amount, amin, amax)
+	}
+	if rmax > rmin && (f.Radius < rmin || f.Radius > rmax || math.IsNaN(f.Radius)) {
+		return fmt.Errorf("%w: %s radius %f not in [%g, %g]", ErrInvalidFilter, f.Kind, f.Radius, rmin, rmax)
+	}
+	return nil
+}
+
+// applyFilters runs the pipeline over img in order.
+func applyFilters(img *image.NRGBA, filters []Filter) *image.NRGBA {
+	for _, f := range filters {
+		img = f.apply(img)
+	}
+	return img
+}
+
+func (f Filter) apply(img *image.NRGBA) *image.NRGBA {
+	switch f.Kind {
+	case FilterUnsharp:
+		return unsharp(img, f.Amount, f.Radius)
+	case FilterBlur:
+		return imaging.Blur(img, f.Radius)
+	case FilterEdgeEnhance:
+		a := f.Amount
+		return imaging.Convolve3x3(img, [9]float64{
+			-a, -a, -a,
+			-a, 1 + 8*a, -a,
+			-a, -a, -a,
+		}, nil)
+	case FilterPosterize:
+		step := 255 / (math.Round(f.Amount) - 1)
+		return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+			q := func(v uint8) uint8 { return clampByte(math.Round(float64(v)/step) * step) }
+			return color.NRGBA{R: q(c.R), G: q(c.G), B: q(c.B), A: c.A}
+		})
+	case FilterEmboss:
+		a := f.Amount
+		return imaging.Convolve3x3(img, [9]float64{
+			-2 * a, -a, 0,
+			-a, 1, a,
+			0, a, 2 * a,
+		}, nil)
+	case FilterBilateral:
+		return bilateral(img, f.Radius, f.Amount)
+	case FilterVignette:
+		return vignette(img, f.Amount, f.Radius)
+	case FilterGamma:
+		return imaging.AdjustGamma(img, f.Amount)
+	case FilterSaturation:
+		return imaging.AdjustSaturation(img, (f.Amount-1)*100)
+	case FilterHue:
+		return imaging.AdjustFunc(img, func(c color.NRGBA) color.NRGBA {
+			return rotateHue(c, f.Amount)
+		})
+	default:
+		return img
+	}
+}
+
+// unsharp adds amount times the difference between img and its blur.
+func unsharp(img *image.NRGBA, amount, sigma float64) *image.NRGBA {
+	blurred := imaging.Blur(img, sigma)
+	out := image.NewNRGBA(img.Rect)
+	for i := 0; i < len(img.Pix); i += 4 {
+		for ch := 0; ch < 3; ch++ {
+			v := float64(img.Pix[i+ch])
+			out.Pix[i+ch] = clampByte(v + amount*(v-float64(blurred.Pix[i+ch])))
+		}
+		out.Pix[i+3] = img.Pix[i+3]
+	}
+	return out
+}
+
+// bilateral averages each pixel with neighbours weighted by distance
+// (spatial sigma) and by color difference (range sigma), so edges stay
+// sharp.
+func bilateral(img *image.NRGBA, spatial, rangeSigma float64) *image.NRGBA {
+	b := img.Rect
+	w, h := b.Dx(), b.Dy()
+	r := int(math.Ceil(2 * spatial))
+	out := image.NewNRGBA(b)
+
+	spatialW := make([]float64, (2*r+1)*(2*r+1))
+	for dy := -r; dy <= r; dy++ {
+		for dx := -r; dx <= r; dx++ {
+			spatialW[(dy+r)*(2*r+1)+dx+r] = math.Exp(-float64(dx*dx+dy*dy) / (2 * spatial * spatial))
+		}
+	}
+	rangeDen := 2 * rangeSigma * rangeSigma
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			ci := y*img.Stride + x*4
+			var sum [3]float64
+			var total float64
+			for dy := -r; dy <= r; dy++ {
+				ny := y + dy
+				if ny < 0 || ny >= h {
+					continue
+				}
+				for dx := -r; dx <= r; dx++ {
+					nx := x + dx
+					if nx < 0 || nx >= w {
+						continue
+					}
+					ni := ny*img.Stride + nx*4
+					var d2 float64
+					for ch := 0; ch < 3; ch++ {
+						d := float64(img.Pix[ni+ch]) - float64(img.Pix[ci+ch])
+						d2 += d * d
+					}
+					wgt := spatialW[(dy+r)*(2*r+1)+dx+r] * math.Exp(-d2/rangeDen)
+					for ch := 0; ch < 3; ch++ {
+						sum[ch] += float64(img.Pix[ni+ch]) * wgt
+					}
+					total += wgt
+				}
+			}
+			oi := y*out.Stride + x*4
+			for ch := 0; ch < 3; ch++ {
+				out.Pix[oi+ch] = clampByte(sum[ch] / total)
+			}
+			out.Pix[oi+3] = img.Pix[ci+3]
+		}
+	}
+	return out
+}
+
+// vignette darkens pixels by up to amount, starting at start (0 centre,
+// 1 corner) of the way out from the centre.
+func vignette(img *image.NRGBA, amount, start float64) *image.NRGBA {
+	b := img.Rect
+	w, h := b.Dx(), b.Dy()
+	cx, cy := float64(w)/2, float64(h)/2
+	maxD := math.Hypot(cx, cy)
+	out := image.NewNRGBA(b)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			d := math.Hypot(float64(x)+0.5-cx, float64(y)+0.5-cy) / maxD
+			t := math.Max(0, math.Min(1, (d-start)/(1-start)))
+			k := 1 - amount*t*t*(3-2*t)
+
+			i := y*img.Stride + x*4
+			o := y*out.Stride + x*4
+			for ch := 0; ch < 3; ch++ {
+				out.Pix[o+ch] = clampByte(float64(img.Pix[i+ch]) * k)
+			}
+			out.Pix[o+3] = img.Pix[i+3]
+		}
+	}
+	return out
+}
+
+// rotateHue turns c's hue by deg degrees, keeping saturation and value.
+func rotateHue(c color.NRGBA, deg float64) color.NRGBA {
+	r, g, b := float64(c.R)/255, float64(c.G)/255, float64(c.B)/255
+	maxC := math.Max(r, math.Max(g, b))
+	minC := math.Min(r, math.Min(g, b))
+	delta := maxC - minC
+	if delta == 0 {
+		return c
+	}
+
+	var h float64
+	switch maxC {
+	case r:
+		h = math.Mod((g-b)/delta, 6)
+	case g:
+		h = (b-r)/delta + 2
+	default:
+		h = (r-g)/delta + 4
+	}
+	h = math.Mod(h*60+deg+720, 360)
+
+	// back from HSV with the same value and chroma
+	x := delta * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	var rr, gg, bb float64
+	switch {
+	case h < 60:
+		rr, gg, bb = delta, x, 0
+	case h < 120:
+		rr, gg, bb = x, delta, 0
+	case h < 180:
+		rr, gg, bb = 0, delta, x
+	case h < 240:
+		rr, gg, bb = 0, x, delta
+	case h < 300:
+		rr, gg, bb = x, 0, delta
+	default:
+		rr, gg, bb = delta, 0, x
+	}
+	return color.NRGBA{
+		R: clampByte((rr + minC) * 255),
+		G: clampByte((gg + minC) * 255),
+		B: clampByte((bb + minC) * 255),
+		A: c.A,
+	}
+}
@@ -0,0 +1,119 @@
+package ascii
+
+import (
+	"fmt"
+	"image"
+	"sync"
+
+	"golang.org/x/image/font"
+	"golang.org/x/image/font/gofont/gomono"
+	"golang.org/x/image/font/opentype"
+	"golang.org/x/image/math/fixed"
+)
+
+// Glyphs are rasterized once at this point size and area-averaged down to
+// whatever cell bitmap the caller asks for.
+const glyphRenderSize = 48.0
+
+type glyphKey struct {
+	r    rune
+	w, h int
+}
+
+var (
+	glyphOnce sync.Once
+	glyphFace font.Face
+	glyphErr  error
+
+	// font.Face is not safe for concurrent use, so the mutex guards both
+	// the face and the cache.
+	glyphMu    sync.Mutex
+	glyphCache = map[glyphKey][]float64{}
+)
+
+func loadGlyphFace() (font.Face, error) {
+	glyphOnce.Do(func() {
+		f, err := opentype.Parse(gomono.TTF)
+		if err != nil {
+			glyphErr = fmt.Errorf("parse glyph font: %w", err)
+			return
+		}
+		glyphFace, glyphErr = opentype.NewFace(f, &opentype.FaceOptions{
+			Size:    glyphRenderSize,
+			DPI:     72,
+			Hinting: font.HintingNone,
+		})
+	})
+	return glyphFace, glyphErr
+}
+
+// glyphMask returns the ink coverage (0–1) of r rasterized onto a w×h cell,
+// row-major. A nil mask means the font has no glyph for r.
+func glyphMask(r rune, w, h int) ([]float64, error) {
+	face, err := loadGlyphFace()
+	if err != nil {
+		return nil, err
+	}
+
+	glyphMu.Lock()
+	defer glyphMu.Unlock()
+
+	key := glyphKey{r: r, w: w, h: h}
+	if mask, ok := glyphCache[key]; ok {
+		return mask, nil
+	}
+
+	if _, ok := face.GlyphAdvance(r); !ok && r != ' ' {
+		glyphCache[key] = nil
+		return nil, nil
+	}
+
+	// every cell is the advance of a monospace glyph by the full line height
+	adv, _ := face.GlyphAdvance('M')
+	metrics := face.Metrics()
+	cw := adv.Ceil()
+	ch := (metrics.Ascent + metrics.Descent).Ceil()
+
+	canvas := image.NewAlpha(image.Rect(0, 0, cw, ch))
+	d := font.Drawer{
+		Dst:  canvas,
+		Src:  image.Opaque,
+		Face: face,
+		Dot:  fixed.Point26_6{X: 0, Y: metrics.Ascent},
+	}
+	d.DrawString(string(r))
+
+	mask := areaAverage(canvas, w, h)
+	glyphCache[key] = mask
+	return mask, nil
+}
+
+// areaAverage downsamples an alpha canvas to w×h by averaging the source
+// pixels each output pixel covers.
+func areaAverage(src *image.Alpha, w, h int) []float64 {
+	sb := src.Bounds()
+	sw, sh := sb.Dx(), sb.Dy()
+	out := make([]float64, w*h)
+
+	for y := 0; y < h; y++ {
+		y0, y1 := y*sh/h, (y+1)*sh/h
+		if y1 <= y0 {
+			y1 = y0 + 1
+		}
+		for x := 0; x < w; x++ {
+			x0, x1 := x*sw/w, (x+1)*sw/w
+			if x1 <= x0 {
+				x1 = x0 + 1
+			}
+
+			var sum float64
+			for sy := y0; sy < y1; sy++ {
+				for sx := x0; sx < x1; sx++ {
+					sum += float64(src.AlphaAt(sx, sy).A)
+				}
+			}
+			out[y*w+x] = sum / float64((x1-x0)*(y1-y0)) / 255.0
+		}
+	}
+	return out
+}
@@ -0,0 +1,213 @@
+package ascii
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	_ "image/gif"  // register GIF
+	_ "image/jpeg" // register JPEG
+	_ "image/png"  // register PNG
+	"io"
+
+	_ "golang.org/x/image/bmp"  // register BMP
+	_ "golang.org/x/image/tiff" // register TIFF
+	_ "golang.org/x/image/webp" // register WebP
+)
+
+// ImageFormat is a container format recognized by its magic bytes.
+type ImageFormat int
+
+const (
+	FormatUnknown ImageFormat = iota
+	FormatPNG
+	FormatJPEG
+	FormatGIF
+	FormatBMP
+	FormatTIFF
+	FormatWebP
+	// recognized, but no decoder is available
+	FormatAVIF
+	FormatHEIC
+	FormatJPEGXL
+	FormatICO
+	FormatSVG
+)
+
+func (f ImageFormat) String() string {
+	switch f {
+	case FormatPNG:
+		return "PNG"
+	case FormatJPEG:
+		return "JPEG"
+	case FormatGIF:
+		return "GIF"
+	case FormatBMP:
+		return "BMP"
+	case FormatTIFF:
+		return "TIFF"
+	case FormatWebP:
+		return "WebP"
+	case FormatAVIF:
+		return "AVIF"
+	case FormatHEIC:
+		return "HEIC"
+	case FormatJPEGXL:
+		return "JPEG XL"
+	case FormatICO:
+		return "ICO"
+	case FormatSVG:
+		return "SVG"
+	default:
+		return "unknown"
+	}
+}
+
+// Supported reports whether images of the format can be decoded.
+func (f ImageFormat) Supported() bool {
+	return f >= FormatPNG && f <= FormatWebP
+}
+
+var (
+	ErrUnknownFormat     = errors.New("not a recognized image format")
+	ErrUnsupportedFormat = errors.New("unsupported image format")
+	ErrCorruptImage      = errors.New("corrupt image")
+)
+
+// Bytes DetectFormat looks at, enough to find the <svg> tag after an XML
+// declaration and a doctype
+const sniffLen = 512
+
+type magic struct {
+	format ImageFormat
+	offset int
+	prefix string
+}
+
+// Magic bytes of each format, checked in order
+var magics = []magic{
+	{FormatPNG, 0, "\x89PNG\r\n\x1a\n"},
+	{FormatJPEG, 0, "\xff\xd8\xff"},
+	{FormatGIF, 0, "GIF87a"},
+	{FormatGIF, 0, "GIF89a"},
+	{FormatBMP, 0, "BM"},
+	{FormatTIFF, 0, "II*\x00"},
+	{FormatTIFF, 0, "MM\x00*"},
+	{FormatWebP, 8, "WEBP"}, // after "RIFF" and the chunk size
+	{FormatAVIF, 4, "ftypavif"},
+	{FormatAVIF, 4, "ftypavis"},
+	{FormatHEIC, 4, "ftypheic"},
+	{FormatHEIC, 4, "ftypheix"},
+	{FormatHEIC, 4, "ftypmif1"},
+	{FormatJPEGXL, 0, "\xff\x0a"},
+	{FormatJPEGXL, 4, "JXL \r\n\x87\n"},
+	{FormatICO, 0, "\x00\x00\x01\x00"},
+	{FormatSVG, 0, "<svg"},
+	{FormatSVG, 0, "<?xml"},
+}
+
+// sniff matches the start of a file against the known magic bytes.
+func sniff(header []byte) ImageFormat {
+	for _, m := range magics {
+		end := m.offset + len(m.prefix)
+		if len(header) < end || string(header[m.offset:end]) != m.prefix {
+			continue
+		}
+		switch {
+		case m.format == FormatWebP && string(header[:4]) != "RIFF":
+			continue
+		case m.format == FormatBMP && !bmpHeader(header):
+			continue
+		case m.prefix == "<?xml" && !bytes.Contains(header, []byte("<svg")):
+			continue
+		}
+		return m.format
+	}
+	return FormatUnknown
+}
+
+// bmpHeader reports whether a file starting with "BM" carries a known DIB
+// header size at offset 14, so text that happens to start with "BM" is not
+// taken for a bitmap.
+func bmpHeader(header []byte) bool {
+	if len(header) < 18 {
+		return false
+	}
+	switch binary.LittleEndian.Uint32(header[14:18]) {
+	case 12, 40, 56, 108, 124:
+		return true
+	default:
+		return false
+	}
+}
+
+// formatError explains why a format cannot be decoded, nil if it can.
+func formatError(f ImageFormat) error {
+	switch {
+	case f == FormatUnknown:
+		return ErrUnknownFormat
+	case !f.Supported():
+		return fmt.Errorf("%w: %s", ErrUnsupportedFormat, f)
+	default:
+		return nil
+	}
+}
+
+// DetectFormat reads the first bytes of r and identifies the image format
+// by content, whatever the file is called. The error tells unknown data
+// from formats that are recognized but cannot be decoded.
+func DetectFormat(r io.Reader) (ImageFormat, error) {
+	header := make([]byte, sniffLen)
+	n, err := io.ReadFull(r, header)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return FormatUnknown, fmt.Errorf("read image: %w", err)
+	}
+	f := sniff(header[:n])
+	return f, formatError(f)
+}
+
+// DecodeImage reads a whole image from r, e.g. a file or stdin, detects
+// its format from the content and turns it upright by its EXIF
+// orientation.
+func DecodeImage(r io.Reader) (image.Image, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("read image: %w", err)
+	}
+	if len(data) == 0 {
+		return nil, fmt.Errorf("%w: empty input", ErrUnknownFormat)
+	}
+
+	f := sniff(data[:min(len(data), sniffLen)])
+	if err := formatError(f); err != nil {
+		return nil, err
+	}
+
+	img, _, err := image.Decode(bytes.NewReader(data))
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s: %w", ErrCorruptImage, f, err)
+	}
+
+	if f == FormatJPEG {
+		o := ReadOrientation(bytes.NewReader(data))
+		img, err = ConvertConfig{Orientation: o}.transform(img)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return img, nil
+}
+
+// ConvertReader decodes an image from r with DecodeImage and converts it.
+// The image is upright already, so cfg.Orientation is best left zero.
+func ConvertReader(r io.Reader, cfg ConvertConfig) (*AsciiResult, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	img, err := DecodeImage(r)
+	if err != nil {
+		return nil, err
+	}
+	return ConvertImage(img, cfg)
+}
@@ -0,0 +1,39 @@
+package ascii
+
+import (
+	"bytes"
+	"errors"
+	"image"
+	"strings"
+	"testing"
+
+	"golang.org/x/image/bmp"
+)
+
+func TestDetectFormatSignatures(t *testing.T) {
+	var bitmap bytes.Buffer
+	if err := bmp.Encode(&bitmap, image.NewNRGBA(image.Rect(0, 0, 2, 2))); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want ImageFormat
+	}{
+		{"bitmap", bitmap.String(), FormatBMP},
+		{"text starting with BM", "BMW service notes, 2024\nOil change due.\n", FormatUnknown},
+		{"bare svg", `<svg xmlns="http://www.w3.org/2000/svg"/>`, FormatSVG},
+		{"svg after xml declaration", "<?xml version=\"1.0\" encoding=\"UTF-8\"?>\n<!DOCTYPE svg>\n<svg width=\"4\"/>", FormatSVG},
+		{"other xml", "<?xml version=\"1.0\"?>\n<feed><title>news</title></feed>", FormatUnknown},
+	}
+	for _, tt := range tests {
+		got, err := DetectFormat(strings.NewReader(tt.data))
+		if got != tt.want {
+			t.Errorf("%s: got %s, want %s", tt.name, got, tt.want)
+		}
+		if tt.want == FormatUnknown && !errors.Is(err, ErrUnknownFormat) {
+			t.Errorf("%s: got error %v, want ErrUnknownFormat", tt.name, err)
+		}
+	}
+}
@@ -0,0 +1,105 @@
+package ascii
+
+import "math"
+
+// Luminance is the model that turns a color into the brightness a cell's
+// character is picked by.
+type Luminance int
+
+const (
+	LuminanceRec601 Luminance = iota // Rec.601 luma of gamma-encoded sRGB (classic)
+	LuminanceRec709                  // Rec.709 / sRGB luma of gamma-encoded sRGB
+	LuminanceLinear                  // relative luminance Y in linear light
+	LuminanceLab                     // CIELAB lightness L*
+
+	luminanceCount
+)
+
+// Luminances lists every luminance model in order.
+func Luminances() []Luminance {
+	return enumValues(luminanceCount)
+}
+
+func (l Luminance) String() string {
+	switch l {
+	case LuminanceRec601:
+		return "Rec.601"
+	case LuminanceRec709:
+		return "Rec.709"
+	case LuminanceLinear:
+		return "Linear"
+	case LuminanceLab:
+		return "L*"
+	default:
+		return "?"
+	}
+}
+
+// srgbLinear is the linear-light value (0–1) of every 8-bit sRGB level.
+var srgbLinear = func() (lut [256]float64) {
+	for i := range lut {
+		lut[i] = srgbToLinear(float64(i) / 255.0)
+	}
+	return lut
+}()
+
+// srgbToLinear decodes an sRGB component (0–1) to linear light.
+func srgbToLinear(v float64) float64 {
+	if v <= 0.04045 {
+		return v / 12.92
+	}
+	return math.Pow((v+0.055)/1.055, 2.4)
+}
+
+// linearToSRGB encodes a linear-light component (0–1) as sRGB.
+func linearToSRGB(v float64) float64 {
+	if v <= 0.0031308 {
+		return v * 12.92
+	}
+	return 1.055*math.Pow(v, 1/2.4) - 0.055
+}
+
+// brightness returns the 0–255 brightness of a gamma-encoded sRGB color.
+func (l Luminance) brightness(r, g, b uint8) float64 {
+	switch l {
+	case LuminanceRec709:
+		return 0.2126*float64(r) + 0.7152*float64(g) + 0.0722*float64(b)
+	case LuminanceLinear:
+		return relativeLuminance(r, g, b) * 255
+	case LuminanceLab:
+		return lightness(relativeLuminance(r, g, b)) / 100 * 255
+	default:
+		return 0.299*float64(r) + 0.587*float64(g) + 0.114*float64(b)
+	}
+}
+
+// relativeLuminance is Y (0–1) of an sRGB color.
+func relativeLuminance(r, g, b uint8) float64 {
+	return 0.2126*srgbLinear[r] + 0.7152*srgbLinear[g] + 0.0722*srgbLinear[b]
+}
+
+// lightness is CIELAB L* (0–100) of relative luminance y, white point Y=1.
+func lightness(y float64) float64 {
+	const eps = 216.0 / 24389.0
+	const kappa = 24389.0 / 27.0
+	if y <= eps {
+		return kappa * y
+	}
+	return 116*math.Cbrt(y) - 16
+}
+
+// adjustLinear applies contrast and brightness to an 8-bit sRGB component
+// in linear light, pivoting contrast around the linear value of mid-gray so
+// the neutral settings leave colors unchanged.
+func adjustLinear(value uint8, contrast, brightness float64) float64 {
+	pivot := srgbLinear[128]
+	v := (srgbLinear[value]-pivot)*contrast + pivot
+	v *= brightness
+	if v <= 0 {
+		return 0
+	}
+	if v >= 1 {
+		return 255
+	}
+	return linearToSRGB(v) * 255
+}
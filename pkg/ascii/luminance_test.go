@@ -0,0 +1,19 @@
+package ascii
+
+import (
+	"image/color"
+	"testing"
+)
+
+func TestAdjustColorNeutralIsIdentity(t *testing.T) {
+	for _, gamma := range []bool{false, true} {
+		cfg := DefaultConfig()
+		cfg.GammaCorrect = gamma
+		for v := 0; v < 256; v++ {
+			c := color.NRGBA{R: uint8(v), G: uint8(255 - v), B: uint8(v / 2), A: 255}
+			if got := adjustColor(c, cfg); got != c {
+				t.Errorf("gamma=%v: %v became %v", gamma, c, got)
+			}
+		}
+	}
+}
@@ -0,0 +1,59 @@
+package ascii
+
+import "math"
+
+// noiseDitherer offsets every pixel by a threshold from a seeded noise
+// function.
+type noiseDitherer struct {
+	name  string
+	noise func(seed uint64) func(x, y int) float64
+}
+
+func (d noiseDitherer) Name() string { return d.name }
+
+func (d noiseDitherer) Apply(gray []float64, width, height, levels int) {
+	d.ApplyOptions(gray, width, height, levels, DefaultDitherOptions)
+}
+
+func (d noiseDitherer) ApplyOptions(gray []float64, width, height, levels int, opts DitherOptions) {
+	offsetDither(gray, width, height, levels, opts, d.noise(opts.Seed))
+}
+
+func (d noiseDitherer) applyColor(buf [][3]float64, width, height int, snap func([3]float64) [3]float64, spread float64, opts DitherOptions) {
+	offsetColor(buf, width, height, snap, spread*opts.Strength, d.noise(opts.Seed))
+}
+
+// whiteNoise gives every pixel an independent uniform random threshold
+// derived from its position and the seed, so the result does not depend on
+// scan order.
+func whiteNoise(seed uint64) func(x, y int) float64 {
+	return func(x, y int) float64 {
+		v := splitmix64(seed ^ splitmix64(uint64(y)<<32|uint64(x)))
+		return float64(v>>11) / (1 << 53)
+	}
+}
+
+// interleavedGradientNoise is Jimenez's interleaved gradient noise, a
+// cheap pattern between ordered and random dithering. The seed shifts the
+// pattern.
+func interleavedGradientNoise(seed uint64) func(x, y int) float64 {
+	s := splitmix64(seed)
+	ox, oy := float64(s&0xfff), float64(s>>32&0xfff)
+
+	return func(x, y int) float64 {
+		// explicit conversions keep the compiler from fusing multiply-adds,
+		// which would change results between architectures
+		f := float64(0.06711056*(float64(x)+ox)) + float64(0.00583715*(float64(y)+oy))
+		f -= math.Floor(f)
+		f = float64(52.9829189 * f)
+		return f - math.Floor(f)
+	}
+}
+
+// splitmix64 is the SplitMix64 finalizer, a fast well-mixed integer hash.
+func splitmix64(v uint64) uint64 {
+	v += 0x9e3779b97f4a7c15
+	v = (v ^ v>>30) * 0xbf58476d1ce4e5b9
+	v = (v ^ v>>27) * 0x94d049bb133111eb
+	return v ^ v>>31
+}
@@ -0,0 +1,334 @@
+package ascii
+
+import (
+	"errors"
+	"fmt"
+	"math"
+	"math/rand/v2"
+	"sync"
+)
+
+// ThresholdMap is a tile of thresholds in [0, 1] that ordered dithering
+// repeats over the image, row-major.
+type ThresholdMap struct {
+	Width, Height int
+	Values        []float64
+}
+
+var ErrInvalidThresholdMap = errors.New("invalid threshold map")
+
+// Validate checks that the map is non-empty, matches its size and holds
+// thresholds in [0, 1].
+func (m ThresholdMap) Validate() error {
+	if m.Width <= 0 || m.Height <= 0 {
+		return fmt.Errorf("%w: size %dx%d", ErrInvalidThresholdMap, m.Width, m.Height)
+	}
+	if len(m.Values) != m.Width*m.Height {
+		return fmt.Errorf("%w: %d values for %dx%d", ErrInvalidThresholdMap, len(m.Values), m.Width, m.Height)
+	}
+	for i, v := range m.Values {
+		if v < 0 || v > 1 || math.IsNaN(v) {
+			return fmt.Errorf("%w: value %f at %d", ErrInvalidThresholdMap, v, i)
+		}
+	}
+	return nil
+}
+
+// rankMap turns a permutation of 0..n-1 into thresholds centred in their
+// bins, so a flat input dithers to its exact average.
+func rankMap(w, h int, rank []int) ThresholdMap {
+	n := float64(len(rank))
+	values := make([]float64, len(rank))
+	for i, r := range rank {
+		values[i] = (float64(r) + 0.5) / n
+	}
+	return ThresholdMap{Width: w, Height: h, Values: values}
+}
+
+// BayerMap returns the n×n Bayer matrix. n must be a power of two.
+func BayerMap(n int) (ThresholdMap, error) {
+	if n < 2 || n&(n-1) != 0 {
+		return ThresholdMap{}, fmt.Errorf("%w: bayer size %d is not a power of two", ErrInvalidThresholdMap, n)
+	}
+
+	rank := []int{0}
+	for size := 1; size < n; size *= 2 {
+		next := make([]int, 4*size*size)
+		for y := 0; y < size; y++ {
+			for x := 0; x < size; x++ {
+				v := 4 * rank[y*size+x]
+				w := 2 * size
+				next[y*w+x] = v
+				next[y*w+x+size] = v + 2
+				next[(y+size)*w+x] = v + 3
+				next[(y+size)*w+x+size] = v + 1
+			}
+		}
+		rank = next
+	}
+	return rankMap(n, n, rank), nil
+}
+
+// Sigma of the Gaussian the void-and-cluster method measures density with
+const blueNoiseSigma = 1.5
+
+// Size of the maps behind DitheringBlueNoise, and how many seeds' maps are
+// kept
+const (
+	blueNoiseSize  = 64
+	blueNoiseCache = 16
+)
+
+var (
+	blueNoiseMu   sync.Mutex
+	blueNoiseMaps = map[uint64]ThresholdMap{}
+)
+
+// blueNoise tiles the blue-noise map for seed, generated on first use.
+func blueNoise(seed uint64) func(x, y int) float64 {
+	blueNoiseMu.Lock()
+	defer blueNoiseMu.Unlock()
+	m, ok := blueNoiseMaps[seed]
+	if !ok {
+		if len(blueNoiseMaps) >= blueNoiseCache {
+			clear(blueNoiseMaps)
+		}
+		// cannot fail for a valid size
+		m, _ = BlueNoiseMap(blueNoiseSize, seed)
+		blueNoiseMaps[seed] = m
+	}
+	return tile(m)
+}
+
+// BlueNoiseMap generates a size×size blue-noise threshold map with
+// Ulichney's void-and-cluster method. The same seed always yields the same
+// map.
+func BlueNoiseMap(size int, seed uint64) (ThresholdMap, error) {
+	if size < 2 || size > 256 {
+		return ThresholdMap{}, fmt.Errorf("%w: blue noise size %d not in [2, 256]", ErrInvalidThresholdMap, size)
+	}
+	vc := newVoidCluster(size)
+	n := size * size
+
+	// random initial pattern of about a tenth of the pixels
+	rng := rand.New(rand.NewPCG(seed, seed^0x9e3779b97f4a7c15))
+	initial := max(1, n/10)
+	for _, i := range rng.Perm(n)[:initial] {
+		vc.set(i, true)
+	}
+
+	// spread it out: move the tightest cluster into the largest void until
+	// that stops changing anything
+	for {
+		c := vc.tightestCluster()
+		vc.set(c, false)
+		v := vc.largestVoid()
+		vc.set(v, true)
+		if v == c {
+			break
+		}
+	}
+	start := append([]bool(nil), vc.ones...)
+	startEnergy := append([]float64(nil), vc.energy...)
+
+	rank := make([]int, n)
+
+	// ranks below the initial pattern: remove clusters
+	for r := initial - 1; r >= 0; r-- {
+		c := vc.tightestCluster()
+		vc.set(c, false)
+		rank[c] = r
+	}
+
+	// ranks above: fill voids
+	copy(vc.ones, start)
+	copy(vc.energy, startEnergy)
+	for r := initial; r < n; r++ {
+		v := vc.largestVoid()
+		vc.set(v, true)
+		rank[v] = r
+	}
+
+	return rankMap(size, size, rank), nil
+}
+
+// voidCluster tracks a binary pattern on a torus and the Gaussian-weighted
+// density of its set pixels at every position.
+type voidCluster struct {
+	size   int
+	ones   []bool
+	energy []float64
+	kernel []float64 // weight by wrapped (dx, dy) offset
+}
+
+func newVoidCluster(size int) *voidCluster {
+	n := size * size
+	vc := &voidCluster{
+		size:   size,
+		ones:   make([]bool, n),
+		energy: make([]float64, n),
+		kernel: make([]float64, n),
+	}
+	for dy := 0; dy < size; dy++ {
+		for dx := 0; dx < size; dx++ {
+			wx := float64(min(dx, size-dx))
+			wy := float64(min(dy, size-dy))
+			vc.kernel[dy*size+dx] = math.Exp(-(wx*wx + wy*wy) / (2 * blueNoiseSigma * blueNoiseSigma))
+		}
+	}
+	return vc
+}
+
+func (vc *voidCluster) set(i int, on bool) {
+	if vc.ones[i] == on {
+		return
+	}
+	vc.ones[i] = on
+	sign := 1.0
+	if !on {
+		sign = -1.0
+	}
+
+	s := vc.size
+	px, py := i%s, i/s
+	for y := 0; y < s; y++ {
+		dy := (y - py + s) % s
+		for x := 0; x < s; x++ {
+			dx := (x - px + s) % s
+			vc.energy[y*s+x] += sign * vc.kernel[dy*s+dx]
+		}
+	}
+}
+
+// tightestCluster is the set pixel with the highest density.
+func (vc *voidCluster) tightestCluster() int {
+	best := -1
+	for i, on := range vc.ones {
+		if on && (best < 0 || vc.energy[i] > vc.energy[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// largestVoid is the unset pixel with the lowest density.
+func (vc *voidCluster) largestVoid() int {
+	best := -1
+	for i, on := range vc.ones {
+		if !on && (best < 0 || vc.energy[i] < vc.energy[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+// orderedDitherer offsets every pixel by its threshold before quantizing.
+// The map is built on first use.
+type orderedDitherer struct {
+	name  string
+	build func() (ThresholdMap, error)
+
+	once *sync.Once
+	m    *ThresholdMap
+}
+
+func newOrderedDitherer(name string, build func() (ThresholdMap, error)) orderedDitherer {
+	return orderedDitherer{name: name, build: build, once: new(sync.Once), m: new(ThresholdMap)}
+}
+
+func (d orderedDitherer) Name() string { return d.name }
+
+func (d orderedDitherer) Apply(gray []float64, width, height, levels int) {
+	d.ApplyOptions(gray, width, height, levels, DefaultDitherOptions)
+}
+
+func (d orderedDitherer) ApplyOptions(gray []float64, width, height, levels int, opts DitherOptions) {
+	offsetDither(gray, width, height, levels, opts, d.threshold())
+}
+
+func (d orderedDitherer) applyColor(buf [][3]float64, width, height int, snap func([3]float64) [3]float64, spread float64, opts DitherOptions) {
+	offsetColor(buf, width, height, snap, spread*opts.Strength, d.threshold())
+}
+
+// threshold looks up the tiled map. Should building the map have failed,
+// every pixel gets the neutral 0.5.
+func (d orderedDitherer) threshold() func(x, y int) float64 {
+	d.once.Do(d.load)
+	return tile(*d.m)
+}
+
+// tile repeats m over the image; an empty map gives the neutral 0.5.
+func tile(m ThresholdMap) func(x, y int) float64 {
+	if len(m.Values) == 0 {
+		return func(int, int) float64 { return 0.5 }
+	}
+	return func(x, y int) float64 {
+		return m.Values[(y%m.Height)*m.Width+x%m.Width]
+	}
+}
+
+func (d orderedDitherer) load() {
+	// built-in maps are known good, and user maps are validated before
+	// registration
+	if m, err := d.build(); err == nil {
+		*d.m = m
+	}
+}
+
+// RegisterThresholdMap registers ordered dithering with a custom threshold
+// map under name.
+func RegisterThresholdMap(name string, m ThresholdMap) (DitheringStrategy, error) {
+	if err := m.Validate(); err != nil {
+		return 0, err
+	}
+	m.Values = append([]float64(nil), m.Values...)
+	return RegisterDitherer(newOrderedDitherer(name, func() (ThresholdMap, error) { return m, nil }))
+}
+
+// offsetDither adds a threshold in [0, 1], centred on zero and scaled to
+// one quantization step, to every pixel before quantizing it.
+func offsetDither(img []float64, w, h, levels int, opts DitherOptions, threshold func(x, y int) float64) {
+	ls := opts.levelSet(levels)
+
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+
+			newVal := ls.offset(img[i], (threshold(x, y)-0.5)*opts.Strength)
+			if newVal < 0 {
+				newVal = 0
+			}
+			if newVal > 255 {
+				newVal = 255
+			}
+			img[i] = newVal
+		}
+	}
+}
+
+// offsetColor is offsetDither for RGB values snapped to a palette. All
+// channels get the same offset.
+func offsetColor(buf [][3]float64, w, h int, snap func([3]float64) [3]float64, spread float64, threshold func(x, y int) float64) {
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			i := y*w + x
+			offset := (threshold(x, y) - 0.5) * spread
+			v := buf[i]
+			for ch := range v {
+				v[ch] += offset
+			}
+			buf[i] = snap(v)
+		}
+	}
+}
+
+func bayer(n int) func() (ThresholdMap, error) {
+	return func() (ThresholdMap, error) { return BayerMap(n) }
+}
+
+// bayer2Edges is the 2×2 map Ordered 2x2 has always used. Its thresholds sit
+// at the bin edges instead of centred like BayerMap's, so existing output
+// stays the same.
+func bayer2Edges() (ThresholdMap, error) {
+	return ThresholdMap{Width: 2, Height: 2, Values: []float64{0.0 / 4.0, 2.0 / 4.0, 3.0 / 4.0, 1.0 / 4.0}}, nil
+}
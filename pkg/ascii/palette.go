@@ -0,0 +1,293 @@
+package ascii
+
+import (
+	"image/color"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ColorProfile is the color capability of the terminal ToANSI writes for.
+type ColorProfile int
+
+const (
+	ColorProfileTrueColor ColorProfile = iota // 24-bit "38;2;r;g;b"
+	ColorProfileANSI256                       // xterm 256-color "38;5;n"
+	ColorProfileANSI16                        // basic 16 colors "30–37", "90–97"
+	ColorProfileNone                          // no escape codes at all
+
+	colorProfileCount
+)
+
+// ColorProfiles lists every color profile, richest first.
+func ColorProfiles() []ColorProfile {
+	return enumValues(colorProfileCount)
+}
+
+func (p ColorProfile) String() string {
+	switch p {
+	case ColorProfileTrueColor:
+		return "truecolor"
+	case ColorProfileANSI256:
+		return "256"
+	case ColorProfileANSI16:
+		return "16"
+	case ColorProfileNone:
+		return "none"
+	default:
+		return "?"
+	}
+}
+
+// DetectColorProfile guesses the terminal's color support from NO_COLOR,
+// COLORTERM and TERM.
+func DetectColorProfile() ColorProfile {
+	if os.Getenv("NO_COLOR") != "" {
+		return ColorProfileNone
+	}
+
+	switch strings.ToLower(os.Getenv("COLORTERM")) {
+	case "truecolor", "24bit":
+		return ColorProfileTrueColor
+	}
+
+	term := strings.ToLower(os.Getenv("TERM"))
+	switch {
+	case term == "" || term == "dumb":
+		return ColorProfileNone
+	case strings.Contains(term, "truecolor") || strings.Contains(term, "direct"):
+		return ColorProfileTrueColor
+	case strings.Contains(term, "256"):
+		return ColorProfileANSI256
+	default:
+		return ColorProfileANSI16
+	}
+}
+
+// Standard xterm values of the 16 basic colors.
+var ansi16Palette = [16]color.NRGBA{
+	{0, 0, 0, 255}, {205, 0, 0, 255}, {0, 205, 0, 255}, {205, 205, 0, 255},
+	{0, 0, 238, 255}, {205, 0, 205, 255}, {0, 205, 205, 255}, {229, 229, 229, 255},
+	{127, 127, 127, 255}, {255, 0, 0, 255}, {0, 255, 0, 255}, {255, 255, 0, 255},
+	{92, 92, 255, 255}, {255, 0, 255, 255}, {0, 255, 255, 255}, {255, 255, 255, 255},
+}
+
+// Channel levels of the xterm 6×6×6 color cube.
+var cubeLevels = [6]uint8{0, 95, 135, 175, 215, 255}
+
+// ansi256Color returns the RGB value of xterm color n.
+func ansi256Color(n int) color.NRGBA {
+	switch {
+	case n < 16:
+		return ansi16Palette[n]
+	case n < 232:
+		n -= 16
+		return color.NRGBA{cubeLevels[n/36], cubeLevels[(n/6)%6], cubeLevels[n%6], 255}
+	default:
+		v := uint8(8 + (n-232)*10)
+		return color.NRGBA{v, v, v, 255}
+	}
+}
+
+// colorDistance is a cheap perceptual distance ("redmean") between two colors.
+func colorDistance(a, b color.NRGBA) float64 {
+	rm := (float64(a.R) + float64(b.R)) / 2
+	dr := float64(a.R) - float64(b.R)
+	dg := float64(a.G) - float64(b.G)
+	db := float64(a.B) - float64(b.B)
+	return (2+rm/256)*dr*dr + 4*dg*dg + (2+(255-rm)/256)*db*db
+}
+
+func nearestCubeLevel(v uint8) int {
+	best := 0
+	for i, l := range cubeLevels {
+		if absDiff(v, l) < absDiff(v, cubeLevels[best]) {
+			best = i
+		}
+	}
+	return best
+}
+
+func absDiff(a, b uint8) int {
+	if a > b {
+		return int(a - b)
+	}
+	return int(b - a)
+}
+
+// ansi256Index maps c onto the color cube or the gray ramp, whichever is
+// closer. The first 16 entries are left out because terminals theme them.
+func ansi256Index(c color.NRGBA) int {
+	ri, gi, bi := nearestCubeLevel(c.R), nearestCubeLevel(c.G), nearestCubeLevel(c.B)
+	cube := 16 + 36*ri + 6*gi + bi
+
+	avg := (int(c.R) + int(c.G) + int(c.B)) / 3
+	g := (avg - 3) / 10
+	if g < 0 {
+		g = 0
+	} else if g > 23 {
+		g = 23
+	}
+	gray := 232 + g
+
+	if colorDistance(c, ansi256Color(gray)) < colorDistance(c, ansi256Color(cube)) {
+		return gray
+	}
+	return cube
+}
+
+func ansi16Index(c color.NRGBA) int {
+	best := 0
+	bestDist := colorDistance(c, ansi16Palette[0])
+	for i := 1; i < len(ansi16Palette); i++ {
+		if d := colorDistance(c, ansi16Palette[i]); d < bestDist {
+			best, bestDist = i, d
+		}
+	}
+	return best
+}
+
+// quantize returns the palette color of the profile closest to c.
+func (p ColorProfile) quantize(c color.NRGBA) color.NRGBA {
+	switch p {
+	case ColorProfileANSI256:
+		return ansi256Color(ansi256Index(c))
+	case ColorProfileANSI16:
+		return ansi16Palette[ansi16Index(c)]
+	default:
+		return c
+	}
+}
+
+// reduced reports whether the profile limits colors to a palette.
+func (p ColorProfile) reduced() bool {
+	return p == ColorProfileANSI256 || p == ColorProfileANSI16
+}
+
+// colorQuantizer snaps colors to a palette. spread is roughly the distance
+// between neighbouring palette entries per channel, which threshold-based
+// dithering scales its offsets by.
+type colorQuantizer struct {
+	nearest func(c color.NRGBA) color.NRGBA
+	spread  float64
+}
+
+// paletteQuantizer returns the quantizer for cfg's custom palette or, if
+// there is none, its color profile. ok is false when colors stay as they are.
+func paletteQuantizer(cfg ConvertConfig) (q colorQuantizer, ok bool) {
+	if len(cfg.Palette) > 0 {
+		palette := cfg.Palette
+		cache := map[color.NRGBA]color.NRGBA{}
+		return colorQuantizer{
+			nearest: func(c color.NRGBA) color.NRGBA {
+				c.A = 255
+				if q, ok := cache[c]; ok {
+					return q
+				}
+				best := palette[0]
+				bestDist := colorDistance(c, best)
+				for _, p := range palette[1:] {
+					if d := colorDistance(c, p); d < bestDist {
+						best, bestDist = p, d
+					}
+				}
+				cache[c] = best
+				return best
+			},
+			// a palette of n colors covers the cube about like a grid with
+			// n^(1/3) levels per channel
+			spread: 255 / math.Max(1, math.Cbrt(float64(len(palette)))-1),
+		}, true
+	}
+
+	switch cfg.ColorProfile {
+	case ColorProfileANSI256:
+		return colorQuantizer{nearest: cfg.ColorProfile.quantize, spread: 255.0 / 5}, true
+	case ColorProfileANSI16:
+		return colorQuantizer{nearest: cfg.ColorProfile.quantize, spread: 255}, true
+	default:
+		return colorQuantizer{}, false
+	}
+}
+
+// quantizeColors maps every color of a w×h grid onto the palette cfg
+// targets. With ColorDither the error is spread per RGB channel by
+// cfg.Dithering; strategies without color support snap to the nearest
+// color. A plain color profile is left to ToANSI, so the other exporters
+// keep the full colors.
+func quantizeColors(colors []color.NRGBA, w, h int, cfg ConvertConfig) {
+	if len(cfg.Palette) == 0 && !cfg.ColorDither {
+		return
+	}
+	q, ok := paletteQuantizer(cfg)
+	if !ok || colors == nil {
+		return
+	}
+
+	buf := make([][3]float64, len(colors))
+	for i, c := range colors {
+		buf[i] = [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	}
+
+	snap := func(v [3]float64) [3]float64 {
+		c := q.nearest(color.NRGBA{R: clampByte(v[0]), G: clampByte(v[1]), B: clampByte(v[2]), A: 255})
+		return [3]float64{float64(c.R), float64(c.G), float64(c.B)}
+	}
+
+	impl, known := cfg.Dithering.ditherer()
+	cd, dithers := impl.(colorDitherer)
+	if cfg.ColorDither && known && dithers {
+		cd.applyColor(buf, w, h, snap, q.spread, cfg.ditherOptions())
+	} else {
+		for i := range buf {
+			buf[i] = snap(buf[i])
+		}
+	}
+
+	for i, v := range buf {
+		colors[i] = color.NRGBA{R: clampByte(v[0]), G: clampByte(v[1]), B: clampByte(v[2]), A: colors[i].A}
+	}
+}
+
+func clampByte(v float64) uint8 {
+	if v < 0 {
+		return 0
+	}
+	if v > 255 {
+		return 255
+	}
+	return uint8(v + 0.5)
+}
+
+// sgrFg returns the SGR parameters selecting c as the foreground color.
+func (p ColorProfile) sgrFg(c color.NRGBA) string {
+	switch p {
+	case ColorProfileANSI256:
+		return "38;5;" + strconv.Itoa(ansi256Index(c))
+	case ColorProfileANSI16:
+		n := ansi16Index(c)
+		if n < 8 {
+			return strconv.Itoa(30 + n)
+		}
+		return strconv.Itoa(90 + n - 8)
+	default:
+		return "38;2;" + strconv.Itoa(int(c.R)) + ";" + strconv.Itoa(int(c.G)) + ";" + strconv.Itoa(int(c.B))
+	}
+}
+
+// sgrBg returns the SGR parameters selecting c as the background color.
+func (p ColorProfile) sgrBg(c color.NRGBA) string {
+	switch p {
+	case ColorProfileANSI256:
+		return "48;5;" + strconv.Itoa(ansi256Index(c))
+	case ColorProfileANSI16:
+		n := ansi16Index(c)
+		if n < 8 {
+			return strconv.Itoa(40 + n)
+		}
+		return strconv.Itoa(100 + n - 8)
+	default:
+		return "48;2;" + strconv.Itoa(int(c.R)) + ";" + strconv.Itoa(int(c.G)) + ";" + strconv.Itoa(int(c.B))
+	}
+}
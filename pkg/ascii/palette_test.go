@@ -0,0 +1,71 @@
+package ascii
+
+import (
+	"image"
+	"image/color"
+	"strings"
+	"testing"
+)
+
+func gradient(w, h int) *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		for x := 0; x < w; x++ {
+			img.SetNRGBA(x, y, color.NRGBA{R: uint8(x * 255 / (w - 1)), G: uint8(y * 255 / (h - 1)), B: 90, A: 255})
+		}
+	}
+	return img
+}
+
+func isANSI16(c color.NRGBA) bool {
+	c.A = 255
+	for _, p := range ansi16Palette {
+		if c == p {
+			return true
+		}
+	}
+	return false
+}
+
+// The profile only shapes the escape codes; HTML and Markdown get the
+// sampled colors.
+func TestColorProfileLeavesResultColors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Resolution = 1
+	cfg.ColorProfile = ColorProfileANSI16
+
+	res, err := ConvertImage(gradient(64, 32), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	reduced := 0
+	for _, c := range res.Colors {
+		if isANSI16(c) {
+			reduced++
+		}
+	}
+	if reduced == len(res.Colors) {
+		t.Error("colors were reduced to the 16-color palette")
+	}
+	if strings.Contains(res.ToANSI(), "38;2;") {
+		t.Error("16-color ToANSI wrote truecolor escapes")
+	}
+}
+
+func TestColorDitherReducesResultColors(t *testing.T) {
+	cfg := DefaultConfig()
+	cfg.Resolution = 1
+	cfg.ColorProfile = ColorProfileANSI16
+	cfg.ColorDither = true
+	cfg.Dithering = DitheringFloydSteinberg
+
+	res, err := ConvertImage(gradient(64, 32), cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, c := range res.Colors {
+		if !isANSI16(c) {
+			t.Fatalf("cell %d: %v is not a 16-color palette entry", i, c)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+package ascii
+
+import (
+	"fmt"
+	"image"
+	"math"
+
+	"github.com/disintegration/imaging"
+)
+
+// Upper bound for PixelScaleX and PixelScaleY
+const maxPixelScale = 64
+
+// subPixels returns how many pixels a cell of the charset draws across and
+// down.
+func (c ConvertConfig) subPixels() (int, int) {
+	switch c.Charset {
+	case CharSetHalfBlocks:
+		return 1, 2
+	case CharSetBraille:
+		return 2, 4
+	case CharSetQuadrants:
+		return 2, 2
+	case CharSetSextants:
+		return 2, 3
+	default:
+		return 1, 1
+	}
+}
+
+// exactSteps returns the pixel-art scales a cell shows every source pixel
+// it covers in its own color at: sx a multiple of stepX, sy a multiple of
+// stepY and at least minY. Braille cells have one color, so they may only
+// cover a single pixel; quadrant and sextant cells split into two colors
+// and may cover two pixels, one above the other.
+func (c ConvertConfig) exactSteps() (stepX, stepY, minY int) {
+	subX, subY := c.subPixels()
+	switch c.Charset {
+	case CharSetBraille:
+		return subX, subY, subY
+	case CharSetQuadrants, CharSetSextants:
+		return subX, 1, subY - 1
+	default:
+		return 1, 1, 1
+	}
+}
+
+// validatePixelScale checks that explicit scales keep every pixel's color.
+func (c ConvertConfig) validatePixelScale() error {
+	if c.PixelScaleX < 0 || c.PixelScaleX > maxPixelScale || c.PixelScaleY < 0 || c.PixelScaleY > maxPixelScale {
+		return fmt.Errorf("%w: %d×%d", ErrInvalidPixelScale, c.PixelScaleX, c.PixelScaleY)
+	}
+	stepX, stepY, minY := c.exactSteps()
+	if c.PixelScaleX%stepX != 0 || c.PixelScaleY > 0 && (c.PixelScaleY%stepY != 0 || c.PixelScaleY < minY) {
+		return fmt.Errorf("%w: %d×%d in %s, needs x a multiple of %d and y a multiple of %d from %d",
+			ErrBlendingPixelScale, c.PixelScaleX, c.PixelScaleY, c.Charset.Name(), stepX, stepY, minY)
+	}
+	return nil
+}
+
+// pixelScale returns how many sub-pixels across and down a source pixel
+// covers in SizePixelArt. Zero scales default to the smallest ones that
+// keep source pixels square on screen, rounded up to exact ones.
+func (c ConvertConfig) pixelScale() (int, int) {
+	if c.PixelScaleX > 0 && c.PixelScaleY > 0 {
+		return c.PixelScaleX, c.PixelScaleY
+	}
+
+	subX, subY := c.subPixels()
+	// height:width of one sub-pixel
+	ratio := c.cellAspect() * float64(subX) / float64(subY)
+	sx, sy := 1, 1
+	if ratio >= 1 {
+		sx = max(1, int(math.Round(ratio)))
+	} else {
+		sy = max(1, int(math.Round(1/ratio)))
+	}
+
+	stepX, stepY, minY := c.exactSteps()
+	sx = (sx + stepX - 1) / stepX * stepX
+	sy = max(minY, (sy+stepY-1)/stepY*stepY)
+
+	if c.PixelScaleX > 0 {
+		sx = c.PixelScaleX
+	}
+	if c.PixelScaleY > 0 {
+		sy = c.PixelScaleY
+	}
+	return sx, sy
+}
+
+// pixelArtSize returns the grid size in cells for an origW×origH image in
+// SizePixelArt, rounded up to whole cells.
+func (c ConvertConfig) pixelArtSize(origW, origH int) (int, int) {
+	subX, subY := c.subPixels()
+	sx, sy := c.pixelScale()
+	return (origW*sx + subX - 1) / subX, (origH*sy + subY - 1) / subY
+}
+
+// pixelArtImage repeats every pixel of img into a block of sub-pixels on a
+// canvas exactly the size of a w×h grid, so resampling it is a no-op. The
+// part of the last row or column of cells the image does not reach stays
+// transparent.
+func (c ConvertConfig) pixelArtImage(img image.Image, w, h int) *image.NRGBA {
+	src := imaging.Clone(img)
+	subX, subY := c.subPixels()
+	sx, sy := c.pixelScale()
+
+	out := image.NewNRGBA(image.Rect(0, 0, w*subX, h*subY))
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	for y := range sh * sy {
+		srcRow := src.Pix[(y/sy)*src.Stride:]
+		dstRow := out.Pix[y*out.Stride:]
+		for x := range sw * sx {
+			copy(dstRow[x*4:x*4+4], srcRow[(x/sx)*4:])
+		}
+	}
+	return out
+}
+
+// forPixelArt returns the config the pixel-art canvas is converted with:
+// nothing may blend neighbouring pixels, and transparent pixels become
+// empty cells unless a matte was asked for.
+func (c ConvertConfig) forPixelArt() ConvertConfig {
+	c.Resampling = ResampleNearest
+	c.Filters = nil
+	c.ColorAggregation = ColorSampled
+	c.ColorDither = false
+	if c.Alpha == AlphaIgnore {
+		c.Alpha = AlphaKeep
+	}
+	return c
+}
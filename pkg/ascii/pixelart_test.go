@@ -0,0 +1,85 @@
+package ascii
+
+import (
+	"errors"
+	"image"
+	"image/color"
+	"testing"
+)
+
+var spritePalette = []color.NRGBA{
+	{R: 230, G: 40, B: 60, A: 255},
+	{R: 20, G: 200, B: 90, A: 255},
+	{R: 40, G: 70, B: 220, A: 255},
+	{R: 250, G: 220, B: 30, A: 255},
+}
+
+// sprite is a 7×5 image with neighbouring pixels in different palette
+// colors, so any blending shows up as a new color.
+func sprite() *image.NRGBA {
+	img := image.NewNRGBA(image.Rect(0, 0, 7, 5))
+	for y := range 5 {
+		for x := range 7 {
+			img.SetNRGBA(x, y, spritePalette[(x+2*y)%len(spritePalette)])
+		}
+	}
+	img.SetNRGBA(3, 2, color.NRGBA{})
+	return img
+}
+
+func TestPixelArtKeepsPalette(t *testing.T) {
+	inPalette := func(c color.NRGBA) bool {
+		if c.A == 0 {
+			return true
+		}
+		for _, p := range spritePalette {
+			if c == p {
+				return true
+			}
+		}
+		return false
+	}
+
+	for _, cs := range []CharSet{CharSetClassic, CharSetHalfBlocks, CharSetBraille, CharSetQuadrants, CharSetSextants} {
+		cfg := DefaultConfig()
+		cfg.SizeMode = SizePixelArt
+		cfg.Charset = cs
+		cfg.Colored = true
+
+		res, err := ConvertImage(sprite(), cfg)
+		if err != nil {
+			t.Fatalf("%s: %v", cs.Name(), err)
+		}
+		for i, c := range res.Colors {
+			if !inPalette(c) {
+				t.Fatalf("%s: cell %d foreground %v is not in the palette", cs.Name(), i, c)
+			}
+		}
+		for i, c := range res.Backgrounds {
+			if !inPalette(c) {
+				t.Fatalf("%s: cell %d background %v is not in the palette", cs.Name(), i, c)
+			}
+		}
+	}
+}
+
+func TestPixelArtRejectsBlendingScales(t *testing.T) {
+	tests := []struct {
+		cs     CharSet
+		sx, sy int
+	}{
+		{CharSetBraille, 1, 4},
+		{CharSetBraille, 2, 2},
+		{CharSetQuadrants, 1, 1},
+		{CharSetSextants, 2, 1},
+	}
+	for _, tt := range tests {
+		cfg := DefaultConfig()
+		cfg.SizeMode = SizePixelArt
+		cfg.Charset = tt.cs
+		cfg.PixelScaleX, cfg.PixelScaleY = tt.sx, tt.sy
+		if err := cfg.Validate(); !errors.Is(err, ErrBlendingPixelScale) {
+			t.Errorf("%s at %d×%d: got %v, want ErrBlendingPixelScale", tt.cs.Name(), tt.sx, tt.sy, err)
+		}
+	}
+}
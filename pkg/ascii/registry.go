@@ -0,0 +1,77 @@
+package ascii
+
+import (
+	"fmt"
+	"sync"
+)
+
+// IDs handed out by registry.register, clear of the built-in constants
+const customIDBase = 1 << 16
+
+// registry holds the built-in and user-registered entries of one kind,
+// e.g. charsets, keyed by the constant that selects them. It is safe for
+// concurrent use.
+type registry[K ~int, V any] struct {
+	mu    sync.RWMutex
+	items map[K]V
+	order []K
+	next  K
+	name  func(V) string
+}
+
+func newRegistry[K ~int, V any](name func(V) string) *registry[K, V] {
+	return &registry[K, V]{items: map[K]V{}, next: customIDBase, name: name}
+}
+
+// add registers a built-in entry under its constant.
+func (r *registry[K, V]) add(id K, v V) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.items[id] = v
+	r.order = append(r.order, id)
+}
+
+// register assigns v the next free ID. A name already taken fails with
+// errDup.
+func (r *registry[K, V]) register(v V, errDup error) (K, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	name := r.name(v)
+	for _, existing := range r.items {
+		if r.name(existing) == name {
+			return 0, fmt.Errorf("%w: %q", errDup, name)
+		}
+	}
+
+	id := r.next
+	r.next++
+	r.items[id] = v
+	r.order = append(r.order, id)
+	return id, nil
+}
+
+func (r *registry[K, V]) get(id K) (V, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	v, ok := r.items[id]
+	return v, ok
+}
+
+// ids lists every entry, built-ins first, in registration order.
+func (r *registry[K, V]) ids() []K {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return append([]K(nil), r.order...)
+}
+
+func (r *registry[K, V]) byName(name string) (K, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	for _, id := range r.order {
+		if r.name(r.items[id]) == name {
+			return id, true
+		}
+	}
+	return 0, false
+}
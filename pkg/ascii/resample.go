@@ -0,0 +1,358 @@
+package ascii
+
+import (
+	"image"
+	"image/color"
+	"math"
+	"sort"
+
+	"github.com/disintegration/imaging"
+)
+
+// Resampling is the filter the source image is resized to the cell grid
+// with.
+type Resampling int
+
+const (
+	ResampleLanczos    Resampling = iota // Lanczos3, sharp with slight ringing (default)
+	ResampleNearest                      // nearest neighbour, no blending
+	ResampleBox                          // box filter
+	ResampleLinear                       // bilinear
+	ResampleCatmullRom                   // bicubic Catmull-Rom
+	ResampleArea                         // exact area average of the covered pixels
+
+	resamplingCount
+)
+
+// Resamplings lists every resampling filter in order.
+func Resamplings() []Resampling {
+	return enumValues(resamplingCount)
+}
+
+func (r Resampling) String() string {
+	switch r {
+	case ResampleLanczos:
+		return "Lanczos"
+	case ResampleNearest:
+		return "nearest"
+	case ResampleBox:
+		return "box"
+	case ResampleLinear:
+		return "linear"
+	case ResampleCatmullRom:
+		return "CatmullRom"
+	case ResampleArea:
+		return "area"
+	default:
+		return "?"
+	}
+}
+
+// ColorAggregation is how a cell's color is computed from the source
+// pixels it covers.
+type ColorAggregation int
+
+const (
+	ColorSampled  ColorAggregation = iota // color of the resampled pixel (default)
+	ColorMean                             // mean of all covered pixels
+	ColorMedian                           // per-channel median of all covered pixels
+	ColorDominant                         // centre of the largest k-means cluster
+
+	colorAggregationCount
+)
+
+// ColorAggregations lists every color aggregation in order.
+func ColorAggregations() []ColorAggregation {
+	return enumValues(colorAggregationCount)
+}
+
+func (a ColorAggregation) String() string {
+	switch a {
+	case ColorSampled:
+		return "sampled"
+	case ColorMean:
+		return "mean"
+	case ColorMedian:
+		return "median"
+	case ColorDominant:
+		return "dominant"
+	default:
+		return "?"
+	}
+}
+
+// k-means settings of ColorDominant
+const (
+	dominantClusters   = 3
+	dominantIterations = 8
+	// cells covering more pixels are subsampled to about this many
+	dominantMaxPixels = 1024
+)
+
+// resize scales img to w×h with the given filter.
+func resize(img image.Image, w, h int, r Resampling) *image.NRGBA {
+	switch r {
+	case ResampleNearest:
+		return imaging.Resize(img, w, h, imaging.NearestNeighbor)
+	case ResampleBox:
+		return imaging.Resize(img, w, h, imaging.Box)
+	case ResampleLinear:
+		return imaging.Resize(img, w, h, imaging.Linear)
+	case ResampleCatmullRom:
+		return imaging.Resize(img, w, h, imaging.CatmullRom)
+	case ResampleArea:
+		return areaResize(imaging.Clone(img), w, h)
+	default:
+		return imaging.Resize(img, w, h, imaging.Lanczos)
+	}
+}
+
+// areaWeights returns, for each of n output pixels over a src pixel axis,
+// the first source pixel it overlaps and how much of each following one it
+// covers.
+func areaWeights(src, n int) (starts []int, weights [][]float64) {
+	starts = make([]int, n)
+	weights = make([][]float64, n)
+	scale := float64(src) / float64(n)
+	for i := range n {
+		lo, hi := float64(i)*scale, float64(i+1)*scale
+		first := int(lo)
+		last := min(src-1, int(math.Ceil(hi))-1)
+		starts[i] = first
+		for j := first; j <= last; j++ {
+			weights[i] = append(weights[i], math.Min(hi, float64(j+1))-math.Max(lo, float64(j)))
+		}
+	}
+	return starts, weights
+}
+
+// areaResize averages every source pixel an output pixel covers, weighted
+// by the covered fraction and by alpha, so no pixel is skipped or counted
+// twice.
+func areaResize(src *image.NRGBA, w, h int) *image.NRGBA {
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	xs, xw := areaWeights(sw, w)
+	ys, yw := areaWeights(sh, h)
+
+	// horizontal pass, premultiplied: sh rows of w pixels
+	tmp := make([][4]float64, sh*w)
+	for y := range sh {
+		row := src.Pix[y*src.Stride:]
+		for x := range w {
+			var acc [4]float64
+			for k, wt := range xw[x] {
+				p := row[(xs[x]+k)*4:]
+				a := float64(p[3]) * wt
+				acc[0] += float64(p[0]) * a
+				acc[1] += float64(p[1]) * a
+				acc[2] += float64(p[2]) * a
+				acc[3] += a
+			}
+			tmp[y*w+x] = acc
+		}
+	}
+
+	out := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := range h {
+		var total float64
+		for _, wt := range yw[y] {
+			total += wt
+		}
+		for x := range w {
+			var acc [4]float64
+			for k, wt := range yw[y] {
+				t := tmp[(ys[y]+k)*w+x]
+				for ch := range acc {
+					acc[ch] += t[ch] * wt
+				}
+			}
+			i := y*out.Stride + x*4
+			if acc[3] > 0 {
+				for ch := 0; ch < 3; ch++ {
+					out.Pix[i+ch] = clampByte(acc[ch] / acc[3])
+				}
+			}
+			var xTotal float64
+			for _, wt := range xw[x] {
+				xTotal += wt
+			}
+			out.Pix[i+3] = clampByte(acc[3] / (total * xTotal))
+		}
+	}
+	return out
+}
+
+// aggregateColors computes the color of every cell of a w×h grid from all
+// source pixels it covers, then runs the filters over the grid and adjusts
+// each cell like a sampled pixel. Colors are weighted by alpha; a cell's
+// alpha is the mean over its pixels.
+func aggregateColors(img image.Image, w, h int, cfg ConvertConfig) []color.NRGBA {
+	src := imaging.Clone(img)
+	sw, sh := src.Rect.Dx(), src.Rect.Dy()
+	grid := image.NewNRGBA(image.Rect(0, 0, w, h))
+	var px []color.NRGBA
+
+	for cy := range h {
+		y0 := cy * sh / h
+		y1 := max(y0+1, (cy+1)*sh/h)
+		for cx := range w {
+			x0 := cx * sw / w
+			x1 := max(x0+1, (cx+1)*sw/w)
+
+			px = px[:0]
+			for y := y0; y < y1; y++ {
+				for x := x0; x < x1; x++ {
+					px = append(px, src.NRGBAAt(x, y))
+				}
+			}
+
+			var c color.NRGBA
+			switch cfg.ColorAggregation {
+			case ColorMedian:
+				c = medianColor(px)
+			case ColorDominant:
+				c = dominantColor(px)
+			default:
+				c = meanColor(px)
+			}
+			grid.SetNRGBA(cx, cy, c)
+		}
+	}
+
+	grid = applyFilters(grid, cfg.Filters)
+	out := make([]color.NRGBA, w*h)
+	for i := range out {
+		out[i] = adjustColor(grid.NRGBAAt(i%w, i/w), cfg)
+	}
+	return out
+}
+
+// meanAlpha is the mean alpha of px.
+func meanAlpha(px []color.NRGBA) uint8 {
+	var a float64
+	for _, p := range px {
+		a += float64(p.A)
+	}
+	return clampByte(a / float64(len(px)))
+}
+
+func meanColor(px []color.NRGBA) color.NRGBA {
+	var r, g, b, a float64
+	for _, p := range px {
+		w := float64(p.A)
+		r += float64(p.R) * w
+		g += float64(p.G) * w
+		b += float64(p.B) * w
+		a += w
+	}
+	if a == 0 {
+		return color.NRGBA{}
+	}
+	return color.NRGBA{
+		R: clampByte(r / a),
+		G: clampByte(g / a),
+		B: clampByte(b / a),
+		A: meanAlpha(px),
+	}
+}
+
+// medianColor takes the median of each channel over the pixels that are
+// not fully transparent.
+func medianColor(px []color.NRGBA) color.NRGBA {
+	var chans [3][]uint8
+	for _, p := range px {
+		if p.A == 0 {
+			continue
+		}
+		chans[0] = append(chans[0], p.R)
+		chans[1] = append(chans[1], p.G)
+		chans[2] = append(chans[2], p.B)
+	}
+	if len(chans[0]) == 0 {
+		return color.NRGBA{}
+	}
+
+	var med [3]uint8
+	for ch, vs := range chans {
+		sort.Slice(vs, func(i, j int) bool { return vs[i] < vs[j] })
+		med[ch] = vs[len(vs)/2]
+	}
+	return color.NRGBA{R: med[0], G: med[1], B: med[2], A: meanAlpha(px)}
+}
+
+// dominantColor clusters the pixels with k-means and returns the centre of
+// the cluster holding the most alpha weight. Centres start at evenly spaced
+// pixels, so the result is deterministic.
+func dominantColor(px []color.NRGBA) color.NRGBA {
+	step := max(1, len(px)/dominantMaxPixels)
+	points := make([][3]float64, 0, len(px)/step+1)
+	weights := make([]float64, 0, cap(points))
+	for i := 0; i < len(px); i += step {
+		p := px[i]
+		if p.A == 0 {
+			continue
+		}
+		points = append(points, [3]float64{float64(p.R), float64(p.G), float64(p.B)})
+		weights = append(weights, float64(p.A))
+	}
+	if len(points) == 0 {
+		return color.NRGBA{}
+	}
+
+	k := min(dominantClusters, len(points))
+	centres := make([][3]float64, k)
+	for c := range centres {
+		centres[c] = points[c*len(points)/k]
+	}
+
+	assign := make([]int, len(points))
+	mass := make([]float64, k)
+	for range dominantIterations {
+		for i, p := range points {
+			best, bestD := 0, math.Inf(1)
+			for c, ctr := range centres {
+				if d := colorDist2(p, ctr); d < bestD {
+					best, bestD = c, d
+				}
+			}
+			assign[i] = best
+		}
+
+		sums := make([][3]float64, k)
+		clear(mass)
+		for i, p := range points {
+			c := assign[i]
+			for ch := range p {
+				sums[c][ch] += p[ch] * weights[i]
+			}
+			mass[c] += weights[i]
+		}
+		for c := range centres {
+			// an empty cluster keeps its centre
+			if mass[c] > 0 {
+				for ch := range sums[c] {
+					centres[c][ch] = sums[c][ch] / mass[c]
+				}
+			}
+		}
+	}
+
+	best := 0
+	for c := range mass {
+		if mass[c] > mass[best] {
+			best = c
+		}
+	}
+	ctr := centres[best]
+	return color.NRGBA{
+		R: clampByte(ctr[0]),
+		G: clampByte(ctr[1]),
+		B: clampByte(ctr[2]),
+		A: meanAlpha(px),
+	}
+}
+
+func colorDist2(a, b [3]float64) float64 {
+	dr, dg, db := a[0]-b[0], a[1]-b[1], a[2]-b[2]
+	return dr*dr + dg*dg + db*db
+}
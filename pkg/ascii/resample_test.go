@@ -0,0 +1,23 @@
+package ascii
+
+import "testing"
+
+// Color filters must reach the cell colors whatever the aggregation.
+func TestAggregationKeepsColorFilters(t *testing.T) {
+	for a := ColorSampled; a <= ColorDominant; a++ {
+		cfg := DefaultConfig()
+		cfg.Resolution = 0.5
+		cfg.ColorAggregation = a
+		cfg.Filters = []Filter{{Kind: FilterSaturation, Amount: 0}}
+
+		res, err := ConvertImage(gradient(64, 32), cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i, c := range res.Colors {
+			if c.R != c.G || c.G != c.B {
+				t.Fatalf("%s: cell %d is %v, want gray after desaturation", a, i, c)
+			}
+		}
+	}
+}
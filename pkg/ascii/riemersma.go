@@ -0,0 +1,198 @@
+package ascii
+
+import "math"
+
+// Default length of the Riemersma error history
+const defaultRiemersmaHistory = 16
+
+// Upper bound for ConvertConfig.RiemersmaHistory
+const maxRiemersmaHistory = 256
+
+// Weight of the newest error relative to the oldest one in the history
+const riemersmaRatio = 16.0
+
+type riemersmaDitherer struct{}
+
+func (riemersmaDitherer) Name() string { return "Riemersma" }
+
+func (d riemersmaDitherer) Apply(gray []float64, width, height, levels int) {
+	riemersma(gray, width, height, levels, DefaultDitherOptions)
+}
+
+func (d riemersmaDitherer) ApplyOptions(gray []float64, width, height, levels int, opts DitherOptions) {
+	riemersma(gray, width, height, levels, opts)
+}
+
+func (d riemersmaDitherer) applyColor(buf [][3]float64, width, height int, snap func([3]float64) [3]float64, _ float64, opts DitherOptions) {
+	riemersmaColor(buf, width, height, snap, opts)
+}
+
+// riemersmaWeights returns the weights of an error history of the given
+// length, rising from 1/ratio for the oldest error to 1 for the newest.
+func riemersmaWeights(n int) []float64 {
+	if n <= 0 {
+		n = defaultRiemersmaHistory
+	}
+	weights := make([]float64, n)
+	for i := range weights {
+		if n == 1 {
+			weights[i] = 1
+			continue
+		}
+		weights[i] = math.Pow(riemersmaRatio, float64(i)/float64(n-1)) / riemersmaRatio
+	}
+	return weights
+}
+
+// riemersma walks the image along a generalized Hilbert curve and adds the
+// exponentially weighted sum of the last few quantization errors to every
+// pixel. Unlike row-wise diffusion the error never travels far from where
+// it was made, so there are no directional artifacts.
+func riemersma(img []float64, width, height, levels int, opts DitherOptions) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	weights := riemersmaWeights(opts.History)
+	n := len(weights)
+
+	ls := opts.levelSet(levels)
+	history := make([]float64, n)
+	head := 0 // index of the oldest entry
+
+	gilbert(width, height, func(x, y int) {
+		var err float64
+		for i, w := range weights {
+			err += history[(head+i)%n] * w
+		}
+
+		idx := y*width + x
+		old := img[idx]
+		newPixel := ls.nearest(old + err)
+		if newPixel < 0 {
+			newPixel = 0
+		} else if newPixel > 255 {
+			newPixel = 255
+		}
+		img[idx] = newPixel
+
+		// the oldest slot becomes the newest
+		history[head] = (old - newPixel) * opts.Strength
+		head = (head + 1) % n
+	})
+}
+
+// riemersmaColor is riemersma for RGB values snapped to a palette, with a
+// history per channel.
+func riemersmaColor(buf [][3]float64, width, height int, snap func([3]float64) [3]float64, opts DitherOptions) {
+	if width <= 0 || height <= 0 {
+		return
+	}
+
+	weights := riemersmaWeights(opts.History)
+	n := len(weights)
+	history := make([][3]float64, n)
+	head := 0
+
+	gilbert(width, height, func(x, y int) {
+		idx := y*width + x
+		old := buf[idx]
+
+		v := old
+		for i, w := range weights {
+			e := history[(head+i)%n]
+			for ch := range v {
+				v[ch] += e[ch] * w
+			}
+		}
+		q := snap(v)
+		buf[idx] = q
+
+		for ch := range q {
+			history[head][ch] = (old[ch] - q[ch]) * opts.Strength
+		}
+		head = (head + 1) % n
+	})
+}
+
+// gilbert calls visit for every pixel of a width×height grid in the order
+// of a generalized Hilbert curve. It covers any rectangle, taking a single
+// diagonal step where odd dimensions leave no other way.
+func gilbert(width, height int, visit func(x, y int)) {
+	if width >= height {
+		gilbert2d(0, 0, width, 0, 0, height, visit)
+	} else {
+		gilbert2d(0, 0, 0, height, width, 0, visit)
+	}
+}
+
+// gilbert2d fills the rectangle spanned from (x, y) by the major axis
+// (ax, ay) and the minor axis (bx, by).
+func gilbert2d(x, y, ax, ay, bx, by int, visit func(x, y int)) {
+	w := absInt(ax + ay)
+	h := absInt(bx + by)
+	dax, day := sign(ax), sign(ay)
+	dbx, dby := sign(bx), sign(by)
+
+	if h == 1 {
+		for range w {
+			visit(x, y)
+			x, y = x+dax, y+day
+		}
+		return
+	}
+	if w == 1 {
+		for range h {
+			visit(x, y)
+			x, y = x+dbx, y+dby
+		}
+		return
+	}
+
+	ax2, ay2 := floorHalf(ax), floorHalf(ay)
+	bx2, by2 := floorHalf(bx), floorHalf(by)
+	w2 := absInt(ax2 + ay2)
+	h2 := absInt(bx2 + by2)
+
+	if 2*w > 3*h {
+		// long rectangle: split along the major axis only, keeping the
+		// halves even so the curve can turn
+		if w2%2 != 0 && w > 2 {
+			ax2, ay2 = ax2+dax, ay2+day
+		}
+		gilbert2d(x, y, ax2, ay2, bx, by, visit)
+		gilbert2d(x+ax2, y+ay2, ax-ax2, ay-ay2, bx, by, visit)
+		return
+	}
+
+	if h2%2 != 0 && h > 2 {
+		bx2, by2 = bx2+dbx, by2+dby
+	}
+	gilbert2d(x, y, bx2, by2, ax2, ay2, visit)
+	gilbert2d(x+bx2, y+by2, ax, ay, bx-bx2, by-by2, visit)
+	gilbert2d(x+(ax-dax)+(bx2-dbx), y+(ay-day)+(by2-dby),
+		-bx2, -by2, -(ax - ax2), -(ay - ay2), visit)
+}
+
+func sign(v int) int {
+	switch {
+	case v < 0:
+		return -1
+	case v > 0:
+		return 1
+	default:
+		return 0
+	}
+}
+
+func absInt(v int) int {
+	if v < 0 {
+		return -v
+	}
+	return v
+}
+
+// floorHalf is v/2 rounded towards negative infinity.
+func floorHalf(v int) int {
+	return v >> 1
+}
@@ -0,0 +1,218 @@
+package ascii
+
+import (
+	"image"
+	"image/color"
+	"math"
+)
+
+type MatchMode int
+
+const (
+	MatchBrightness MatchMode = iota // ramp lookup by average cell brightness
+	MatchShapeSSE                    // closest glyph shape by sum of squared error
+	MatchShapeSSIM                   // closest glyph shape by structural similarity
+
+	matchModeCount
+)
+
+// MatchModes lists every match mode in order.
+func MatchModes() []MatchMode {
+	return enumValues(matchModeCount)
+}
+
+// Width of the bitmap each cell and glyph are compared at. Its height
+// follows the configured cell aspect.
+const shapeCellW = 6
+
+// Weight of the shape term against the tone term in MatchShapeSSE. Binary
+// glyph masks have far more variance than photos, so shape only nudges the
+// choice between glyphs of similar density.
+const shapeWeight = 0.25
+
+// SSIM stabilisers for a dynamic range of 1.0
+const (
+	ssimC1 = 0.01 * 0.01
+	ssimC2 = 0.03 * 0.03
+
+	// variance at which structure weighs as much as tone
+	ssimFlat = 0.01
+)
+
+type glyphSet struct {
+	runes []rune
+	masks [][]float64
+	means []float64
+}
+
+// newGlyphSet rasterizes every rune of the ramp. Masks are normalized so the
+// densest glyph has a mean of 1.0, which puts them on the same scale as the
+// 0–1 source brightness. Runes the font lacks fall back to a flat mask at
+// their position in the ramp.
+func newGlyphSet(ramp []rune, w, h int) (*glyphSet, error) {
+	n := w * h
+	gs := &glyphSet{
+		runes: ramp,
+		masks: make([][]float64, len(ramp)),
+		means: make([]float64, len(ramp)),
+	}
+
+	maxMean := 0.0
+	for i, r := range ramp {
+		mask, err := glyphMask(r, w, h)
+		if err != nil {
+			return nil, err
+		}
+		if mask != nil {
+			gs.means[i] = mean(mask)
+			maxMean = math.Max(maxMean, gs.means[i])
+		}
+		gs.masks[i] = mask
+	}
+	if maxMean == 0 {
+		maxMean = 1
+	}
+
+	for i, mask := range gs.masks {
+		norm := make([]float64, n)
+		if mask == nil {
+			level := 0.0
+			if len(ramp) > 1 {
+				level = float64(i) / float64(len(ramp)-1)
+			}
+			for j := range norm {
+				norm[j] = level
+			}
+			gs.means[i] = level
+		} else {
+			for j, v := range mask {
+				norm[j] = v / maxMean
+			}
+			gs.means[i] /= maxMean
+		}
+		gs.masks[i] = norm
+	}
+	return gs, nil
+}
+
+// match returns the glyph closest to block (0–1 brightness, row-major
+// cell bitmap of the glyph set's size) under the given metric.
+func (gs *glyphSet) match(block []float64, metric MatchMode) rune {
+	best := 0
+	bestScore := math.Inf(1)
+
+	mt := mean(block)
+	vt := variance(block, mt)
+
+	for i, mask := range gs.masks {
+		mg := gs.means[i]
+		var vg, cov, diff float64
+		for j, g := range mask {
+			dg := g - mg
+			dt := block[j] - mt
+			vg += dg * dg
+			cov += dg * dt
+			d := dt - dg
+			diff += d * d
+		}
+		n := float64(len(mask))
+		vg /= n
+		cov /= n
+
+		var score float64
+		switch metric {
+		case MatchShapeSSIM:
+			// flat cells carry no structure worth matching, so the
+			// contrast-structure term fades in with the cell's own variance
+			l := (2*mt*mg + ssimC1) / (mt*mt + mg*mg + ssimC1)
+			cs := (2*cov + ssimC2) / (vt + vg + ssimC2)
+			if cs < 0 {
+				cs = 0
+			}
+			score = -l * math.Pow(cs, vt/(vt+ssimFlat))
+		default:
+			tone := mt - mg
+			score = diff*shapeWeight + n*tone*tone
+		}
+
+		if score < bestScore {
+			bestScore = score
+			best = i
+		}
+	}
+	return gs.runes[best]
+}
+
+// shapeCells samples img at a small bitmap per cell and picks each cell's
+// glyph by shape.
+func shapeCells(img image.Image, w, h int, ramp []rune, cfg ConvertConfig) (*cells, error) {
+	cw := shapeCellW
+	ch := max(1, int(math.Round(shapeCellW*cfg.cellAspect())))
+
+	gs, err := newGlyphSet(ramp, cw, ch)
+	if err != nil {
+		return nil, err
+	}
+
+	pw := w * cw
+	gray, pixels := sample(img, pw, h*ch, cfg)
+
+	out := newCells(w*h, false)
+	block := make([]float64, cw*ch)
+	n := float64(len(block))
+
+	for cy := 0; cy < h; cy++ {
+		for cx := 0; cx < w; cx++ {
+			var r, g, b, a float64
+			for y := 0; y < ch; y++ {
+				for x := 0; x < cw; x++ {
+					i := (cy*ch+y)*pw + cx*cw + x
+					v := gray[i] / 255.0
+					if cfg.Inverted {
+						v = 1 - v
+					}
+					block[y*cw+x] = v
+
+					r += float64(pixels[i].R)
+					g += float64(pixels[i].G)
+					b += float64(pixels[i].B)
+					a += float64(pixels[i].A)
+				}
+			}
+
+			ci := cy*w + cx
+			out.chars[ci] = gs.match(block, cfg.Matching)
+			out.fg[ci] = color.NRGBA{
+				R: uint8(r / n),
+				G: uint8(g / n),
+				B: uint8(b / n),
+				A: uint8(a / n),
+			}
+			out.gray[ci] = getBrightness(out.fg[ci].R, out.fg[ci].G, out.fg[ci].B, cfg.Luminance)
+		}
+	}
+	return out, nil
+}
+
+func mean(v []float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range v {
+		sum += x
+	}
+	return sum / float64(len(v))
+}
+
+func variance(v []float64, m float64) float64 {
+	if len(v) == 0 {
+		return 0
+	}
+	var sum float64
+	for _, x := range v {
+		d := x - m
+		sum += d * d
+	}
+	return sum / float64(len(v))
+}
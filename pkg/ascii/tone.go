@@ -0,0 +1,198 @@
+package ascii
+
+import (
+	"math"
+	"sort"
+)
+
+// ToneMapping stretches the brightness of the sampled image before it is
+// mapped to characters.
+type ToneMapping int
+
+const (
+	ToneNone       ToneMapping = iota // brightness as sampled
+	ToneAutoLevels                    // stretch the BlackPoint–WhitePoint percentiles to 0–255
+	ToneEqualize                      // global histogram equalization
+	ToneCLAHE                         // contrast-limited adaptive histogram equalization
+
+	toneMappingCount
+)
+
+// ToneMappings lists every tone mapping in order.
+func ToneMappings() []ToneMapping {
+	return enumValues(toneMappingCount)
+}
+
+func (t ToneMapping) String() string {
+	switch t {
+	case ToneNone:
+		return "none"
+	case ToneAutoLevels:
+		return "levels"
+	case ToneEqualize:
+		return "equalize"
+	case ToneCLAHE:
+		return "CLAHE"
+	default:
+		return "?"
+	}
+}
+
+// CLAHE defaults for zero ClaheTile and ClaheClip
+const (
+	defaultClaheTile = 8
+	defaultClaheClip = 2.0
+)
+
+const histogramBins = 256
+
+// applyTone runs cfg's tone mapping on a w×h brightness buffer in place.
+func applyTone(gray []float64, w, h int, cfg ConvertConfig) {
+	switch cfg.ToneMapping {
+	case ToneAutoLevels:
+		autoLevels(gray, cfg.BlackPoint, cfg.WhitePoint)
+	case ToneEqualize:
+		lut := equalizeLUT(histogram(gray, w, 0, 0, w, h), math.Inf(1))
+		for i, v := range gray {
+			gray[i] = lut[bin(v)]
+		}
+	case ToneCLAHE:
+		tile := cfg.ClaheTile
+		if tile == 0 {
+			tile = defaultClaheTile
+		}
+		clip := cfg.ClaheClip
+		if clip == 0 {
+			clip = defaultClaheClip
+		}
+		clahe(gray, w, h, tile, clip)
+	}
+}
+
+// autoLevels maps the black and white percentiles (0–100) to 0 and 255.
+func autoLevels(gray []float64, black, white float64) {
+	if len(gray) == 0 {
+		return
+	}
+	sorted := append([]float64(nil), gray...)
+	sort.Float64s(sorted)
+
+	at := func(p float64) float64 {
+		i := int(math.Round(p / 100 * float64(len(sorted)-1)))
+		return sorted[max(0, min(len(sorted)-1, i))]
+	}
+	lo, hi := at(black), at(white)
+	if hi <= lo {
+		return
+	}
+
+	for i, v := range gray {
+		gray[i] = math.Max(0, math.Min(255, (v-lo)/(hi-lo)*255))
+	}
+}
+
+func bin(v float64) int {
+	return max(0, min(histogramBins-1, int(v)))
+}
+
+// histogram counts the brightness of the x0,y0–x1,y1 region of a buffer
+// that is stride pixels wide.
+func histogram(gray []float64, stride, x0, y0, x1, y1 int) []float64 {
+	hist := make([]float64, histogramBins)
+	for y := y0; y < y1; y++ {
+		for x := x0; x < x1; x++ {
+			hist[bin(gray[y*stride+x])]++
+		}
+	}
+	return hist
+}
+
+// equalizeLUT turns a histogram into an equalizing lookup table. Bins above
+// clip times the average count are cut and the excess spread evenly over
+// all bins.
+func equalizeLUT(hist []float64, clip float64) []float64 {
+	var total float64
+	for _, c := range hist {
+		total += c
+	}
+	lut := make([]float64, len(hist))
+
+	limit := clip * total / float64(len(hist))
+	if !math.IsInf(limit, 1) {
+		var excess float64
+		for i, c := range hist {
+			if c > limit {
+				excess += c - limit
+				hist[i] = limit
+			}
+		}
+		share := excess / float64(len(hist))
+		for i := range hist {
+			hist[i] += share
+		}
+	}
+
+	// the darkest occupied bin maps to 0
+	cdfMin := 0.0
+	for _, c := range hist {
+		if c > 0 {
+			cdfMin = c
+			break
+		}
+	}
+	if total-cdfMin <= 0 {
+		for i := range lut {
+			lut[i] = float64(i)
+		}
+		return lut
+	}
+
+	var cdf float64
+	for i, c := range hist {
+		cdf += c
+		lut[i] = math.Max(0, (cdf-cdfMin)/(total-cdfMin)*255)
+	}
+	return lut
+}
+
+// clahe equalizes tile×tile regions separately, limiting each histogram
+// bin to clip times its average, and blends the neighbouring tiles'
+// mappings bilinearly so no seams show.
+func clahe(gray []float64, w, h, tile int, clip float64) {
+	tx := (w + tile - 1) / tile
+	ty := (h + tile - 1) / tile
+
+	luts := make([][]float64, tx*ty)
+	for j := 0; j < ty; j++ {
+		for i := 0; i < tx; i++ {
+			x0, y0 := i*tile, j*tile
+			x1, y1 := min(w, x0+tile), min(h, y0+tile)
+			luts[j*tx+i] = equalizeLUT(histogram(gray, w, x0, y0, x1, y1), clip)
+		}
+	}
+
+	// position of a pixel between tile centres, clamped at the borders
+	coord := func(p, n int) (int, int, float64) {
+		f := (float64(p)+0.5)/float64(tile) - 0.5
+		if f <= 0 {
+			return 0, 0, 0
+		}
+		if f >= float64(n-1) {
+			return n - 1, n - 1, 0
+		}
+		a := int(f)
+		return a, a + 1, f - float64(a)
+	}
+
+	for y := 0; y < h; y++ {
+		j0, j1, fy := coord(y, ty)
+		for x := 0; x < w; x++ {
+			i0, i1, fx := coord(x, tx)
+			b := bin(gray[y*w+x])
+
+			top := luts[j0*tx+i0][b]*(1-fx) + luts[j0*tx+i1][b]*fx
+			bottom := luts[j1*tx+i0][b]*(1-fx) + luts[j1*tx+i1][b]*fx
+			gray[y*w+x] = top*(1-fy) + bottom*fy
+		}
+	}
+}
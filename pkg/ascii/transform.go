@@ -0,0 +1,213 @@
+package ascii
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"image"
+	"io"
+
+	"github.com/disintegration/imaging"
+)
+
+// Orientation is the EXIF orientation tag (1–8) of the source image. Each
+// constant names the turn that puts the image upright.
+type Orientation int
+
+const (
+	OrientationUnknown    Orientation = iota // no tag, drawn as stored
+	OrientationNormal                        // upright already
+	OrientationFlipH                         // mirrored left to right
+	OrientationRotate180                     // upside down
+	OrientationFlipV                         // mirrored top to bottom
+	OrientationTranspose                     // mirrored along the top-left diagonal
+	OrientationRotate90                      // needs a quarter turn clockwise
+	OrientationTransverse                    // mirrored along the top-right diagonal
+	OrientationRotate270                     // needs a quarter turn counter-clockwise
+)
+
+// Rotation turns the image clockwise before conversion.
+type Rotation int
+
+const (
+	Rotate0   Rotation = iota // as is
+	Rotate90                  // a quarter turn clockwise
+	Rotate180                 // half a turn
+	Rotate270                 // a quarter turn counter-clockwise
+
+	rotationCount
+)
+
+// Rotations lists every rotation, clockwise from upright.
+func Rotations() []Rotation {
+	return enumValues(rotationCount)
+}
+
+func (r Rotation) String() string {
+	switch r {
+	case Rotate0:
+		return "0°"
+	case Rotate90:
+		return "90°"
+	case Rotate180:
+		return "180°"
+	case Rotate270:
+		return "270°"
+	default:
+		return "?"
+	}
+}
+
+var (
+	ErrInvalidOrientation = errors.New("orientation must be in [0, 8]")
+	ErrInvalidRotation    = errors.New("unknown rotation")
+	ErrInvalidCrop        = errors.New("invalid crop rectangle")
+)
+
+// validateTransform checks the geometry settings of c.
+func (c ConvertConfig) validateTransform() error {
+	if c.Orientation < OrientationUnknown || c.Orientation > OrientationRotate270 {
+		return fmt.Errorf("%w: %d", ErrInvalidOrientation, c.Orientation)
+	}
+	if c.Rotation < Rotate0 || c.Rotation > Rotate270 {
+		return fmt.Errorf("%w: %d", ErrInvalidRotation, c.Rotation)
+	}
+	if c.Crop != (image.Rectangle{}) && (c.Crop.Empty() || c.Crop.Min.X < 0 || c.Crop.Min.Y < 0) {
+		return fmt.Errorf("%w: %v", ErrInvalidCrop, c.Crop)
+	}
+	return nil
+}
+
+// transform applies the EXIF orientation, then Rotation, then the flips,
+// then the crop. An image that needs none of them is returned as is.
+func (c ConvertConfig) transform(img image.Image) (image.Image, error) {
+	switch c.Orientation {
+	case OrientationFlipH:
+		img = imaging.FlipH(img)
+	case OrientationRotate180:
+		img = imaging.Rotate180(img)
+	case OrientationFlipV:
+		img = imaging.FlipV(img)
+	case OrientationTranspose:
+		img = imaging.Transpose(img)
+	case OrientationRotate90:
+		img = imaging.Rotate270(img) // imaging turns counter-clockwise
+	case OrientationTransverse:
+		img = imaging.Transverse(img)
+	case OrientationRotate270:
+		img = imaging.Rotate90(img)
+	}
+
+	switch c.Rotation {
+	case Rotate90:
+		img = imaging.Rotate270(img)
+	case Rotate180:
+		img = imaging.Rotate180(img)
+	case Rotate270:
+		img = imaging.Rotate90(img)
+	}
+
+	if c.FlipH {
+		img = imaging.FlipH(img)
+	}
+	if c.FlipV {
+		img = imaging.FlipV(img)
+	}
+
+	if c.Crop != (image.Rectangle{}) {
+		b := img.Bounds()
+		r := c.Crop.Add(b.Min).Intersect(b)
+		if r.Empty() {
+			return nil, fmt.Errorf("%w: %v outside the %d×%d image", ErrInvalidCrop, c.Crop, b.Dx(), b.Dy())
+		}
+		img = imaging.Crop(img, r)
+	}
+	return img, nil
+}
+
+// ReadOrientation returns the EXIF orientation of a JPEG stream, or
+// OrientationUnknown if r is no JPEG or carries no valid tag. It reads no
+// further than the tag.
+func ReadOrientation(r io.Reader) Orientation {
+	const (
+		markerSOI      = 0xffd8
+		markerAPP1     = 0xffe1
+		exifHeader     = 0x45786966 // "Exif"
+		orientationTag = 0x0112
+	)
+
+	read := func(order binary.ByteOrder, v any) bool {
+		return binary.Read(r, order, v) == nil
+	}
+	skip := func(n int64) bool {
+		_, err := io.CopyN(io.Discard, r, n)
+		return err == nil
+	}
+
+	var soi uint16
+	if !read(binary.BigEndian, &soi) || soi != markerSOI {
+		return OrientationUnknown
+	}
+
+	// segments up to APP1
+	for {
+		var marker, size uint16
+		if !read(binary.BigEndian, &marker) || !read(binary.BigEndian, &size) || marker>>8 != 0xff {
+			return OrientationUnknown
+		}
+		if marker == markerAPP1 {
+			break
+		}
+		if size < 2 || !skip(int64(size-2)) {
+			return OrientationUnknown
+		}
+	}
+
+	var header uint32
+	if !read(binary.BigEndian, &header) || header != exifHeader || !skip(2) {
+		return OrientationUnknown
+	}
+
+	// TIFF header: byte order, magic, offset of the first directory
+	var orderTag uint16
+	if !read(binary.BigEndian, &orderTag) {
+		return OrientationUnknown
+	}
+	var order binary.ByteOrder
+	switch orderTag {
+	case 0x4d4d: // "MM"
+		order = binary.BigEndian
+	case 0x4949: // "II"
+		order = binary.LittleEndian
+	default:
+		return OrientationUnknown
+	}
+	var offset uint32
+	if !skip(2) || !read(order, &offset) || offset < 8 || !skip(int64(offset-8)) {
+		return OrientationUnknown
+	}
+
+	var count uint16
+	if !read(order, &count) {
+		return OrientationUnknown
+	}
+	for range count {
+		var tag uint16
+		if !read(order, &tag) {
+			return OrientationUnknown
+		}
+		if tag != orientationTag {
+			if !skip(10) {
+				return OrientationUnknown
+			}
+			continue
+		}
+		// type and count come first, the short value after them
+		var v uint16
+		if !skip(6) || !read(order, &v) || v < 1 || v > 8 {
+			return OrientationUnknown
+		}
+		return Orientation(v)
+	}
+	return OrientationUnknown
+}
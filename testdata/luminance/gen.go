@@ -0,0 +1,110 @@
+//go:build ignore
+
+// gen writes the luminance test images. Run from this directory:
+//
+//	go run gen.go
+package main
+
+import (
+	"image"
+	"image/color"
+	"image/png"
+	"log"
+	"math"
+	"os"
+)
+
+func main() {
+	write("saturated.png", saturated())
+	write("dark_gradient.png", darkGradient())
+	write("hue_sweep.png", hueSweep())
+}
+
+func write(name string, img image.Image) {
+	f, err := os.Create(name)
+	if err != nil {
+		log.Fatal(err)
+	}
+	defer f.Close()
+	if err := png.Encode(f, img); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// saturated: fully saturated primaries and secondaries over a strip of the
+// gray each one has under Rec.601, so the models' disagreement shows as
+// contrast between the two halves.
+func saturated() image.Image {
+	bars := []color.NRGBA{
+		{255, 0, 0, 255}, {0, 255, 0, 255}, {0, 0, 255, 255},
+		{0, 255, 255, 255}, {255, 0, 255, 255}, {255, 255, 0, 255},
+	}
+	const bw, h = 80, 160
+	img := image.NewNRGBA(image.Rect(0, 0, bw*len(bars), h))
+	for i, c := range bars {
+		y601 := uint8(0.299*float64(c.R) + 0.587*float64(c.G) + 0.114*float64(c.B) + 0.5)
+		gray := color.NRGBA{y601, y601, y601, 255}
+		for y := 0; y < h; y++ {
+			for x := i * bw; x < (i+1)*bw; x++ {
+				if y < h/2 {
+					img.SetNRGBA(x, y, c)
+				} else {
+					img.SetNRGBA(x, y, gray)
+				}
+			}
+		}
+	}
+	return img
+}
+
+// darkGradient: sRGB levels 0–64 left to right, the range where
+// gamma-encoded and linear processing differ most.
+func darkGradient() image.Image {
+	const w, h = 520, 80
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for x := 0; x < w; x++ {
+		v := uint8(x * 64 / (w - 1))
+		for y := 0; y < h; y++ {
+			img.SetNRGBA(x, y, color.NRGBA{v, v, v, 255})
+		}
+	}
+	return img
+}
+
+// hueSweep: hue left to right at full saturation, value falling top to
+// bottom.
+func hueSweep() image.Image {
+	const w, h = 720, 240
+	img := image.NewNRGBA(image.Rect(0, 0, w, h))
+	for y := 0; y < h; y++ {
+		v := 1 - float64(y)/float64(h-1)
+		for x := 0; x < w; x++ {
+			r, g, b := hsv(float64(x)/float64(w)*360, 1, v)
+			img.SetNRGBA(x, y, color.NRGBA{r, g, b, 255})
+		}
+	}
+	return img
+}
+
+func hsv(h, s, v float64) (uint8, uint8, uint8) {
+	c := v * s
+	x := c * (1 - math.Abs(math.Mod(h/60, 2)-1))
+	m := v - c
+	var r, g, b float64
+	switch {
+	case h < 60:
+		r, g, b = c, x, 0
+	case h < 120:
+		r, g, b = x, c, 0
+	case h < 180:
+		r, g, b = 0, c, x
+	case h < 240:
+		r, g, b = 0, x, c
+	case h < 300:
+		r, g, b = x, 0, c
+	default:
+		r, g, b = c, 0, x
+	}
+	to8 := func(f float64) uint8 { return uint8(math.Round((f + m) * 255)) }
+	return to8(r), to8(g), to8(b)
+}
@@ -19,18 +19,38 @@ const (
 	modeView
 	modePickPathInput
 	modeViewSaveName
+	modeViewFilters
+	modeViewCrop
 )
 
 type field int
 
 const (
 	fieldResolution field = iota
+	fieldResample
+	fieldPixelArt
+	fieldRotate
+	fieldFlip
 	fieldContrast
 	fieldBrightness
+	fieldGamma
+	fieldLuminance
+	fieldTone
 	fieldDither
+	fieldSerpentine
+	fieldStrength
+	fieldSeed
 	fieldCharSet
+	fieldCalibrate
 	fieldColor
+	fieldAggregate
 	fieldInvert
+	fieldEdges
+	fieldMatch
+	fieldProfile
+	fieldColorDither
+	fieldTolerance
+	fieldAlpha
 	fieldCount
 )
 
@@ -57,6 +77,15 @@ type Model struct {
 
 	art string
 
+	// filter panel
+	filterSel int
+
+	// crop tool, in pixels of the rotated image
+	cropSel      image.Rectangle
+	cropPrev     image.Rectangle
+	cropAnchor   image.Point
+	cropDragging bool
+
 	// save input
 	saveKind string
 	saveName string
@@ -76,6 +105,7 @@ func NewViewerModel(img image.Image, path string) *Model {
 	cfg := ascii.DefaultConfig()
 	cfg.Colored = false
 	cfg.Dithering = ascii.DitheringFloydSteinberg
+	cfg.ColorProfile = ascii.DetectColorProfile()
 
 	m := Model{
 		mode:    modeView,
@@ -127,7 +157,9 @@ func (m *Model) updateArtString() {
 		m.art = ""
 		return
 	}
-	if m.cfg.Colored {
+	if m.mode == modeViewCrop {
+		m.art = m.cropOverlay()
+	} else if m.cfg.Colored {
 		m.art = m.res.ToANSI()
 	} else {
 		m.art = m.res.ToPlainText()
@@ -149,18 +181,66 @@ func (m *Model) adjustCurrent(dir int) {
 	switch m.focused {
 	case fieldResolution:
 		m.cfg.Resolution = clamp(m.cfg.Resolution+step(0.02), 0.05, 1.0)
+	case fieldResample:
+		m.cfg.Resampling = cycle(m.cfg.Resampling, ascii.Resamplings())
+	case fieldPixelArt:
+		if m.cfg.SizeMode == ascii.SizePixelArt {
+			m.cfg.SizeMode = ascii.SizeScale
+		} else {
+			m.cfg.SizeMode = ascii.SizePixelArt
+		}
+	case fieldRotate:
+		m.cfg.Rotation = cycle(m.cfg.Rotation, ascii.Rotations())
+		// the crop was drawn on the old orientation
+		m.cfg.Crop = image.Rectangle{}
+	case fieldFlip:
+		// none, horizontal, vertical, both
+		m.cfg.FlipH, m.cfg.FlipV = !m.cfg.FlipH, m.cfg.FlipV != m.cfg.FlipH
+		m.cfg.Crop = image.Rectangle{}
 	case fieldContrast:
 		m.cfg.Contrast = clamp(m.cfg.Contrast+step(0.05), 0.1, 3.0)
 	case fieldBrightness:
 		m.cfg.Brightness = clamp(m.cfg.Brightness+step(0.05), 0.1, 3.0)
+	case fieldGamma:
+		m.cfg.GammaCorrect = !m.cfg.GammaCorrect
+	case fieldLuminance:
+		m.cfg.Luminance = cycle(m.cfg.Luminance, ascii.Luminances())
+	case fieldTone:
+		m.cfg.ToneMapping = cycle(m.cfg.ToneMapping, ascii.ToneMappings())
 	case fieldDither:
-		m.cfg.Dithering = cycleDither(m.cfg.Dithering)
+		m.cfg.Dithering = cycle(m.cfg.Dithering, ascii.DitheringStrategies())
+	case fieldSerpentine:
+		m.cfg.Serpentine = !m.cfg.Serpentine
+	case fieldStrength:
+		m.cfg.DitherStrength = clamp(m.cfg.DitherStrength+step(0.05), 0, 1.0)
+	case fieldSeed:
+		if dir < 0 && m.cfg.Seed > 0 {
+			m.cfg.Seed--
+		} else if dir > 0 {
+			m.cfg.Seed++
+		}
 	case fieldColor:
 		m.cfg.Colored = !m.cfg.Colored
+	case fieldAggregate:
+		m.cfg.ColorAggregation = cycle(m.cfg.ColorAggregation, ascii.ColorAggregations())
 	case fieldInvert:
 		m.cfg.Inverted = !m.cfg.Inverted
+	case fieldEdges:
+		m.cfg.EdgeDetection = !m.cfg.EdgeDetection
+	case fieldMatch:
+		m.cfg.Matching = cycle(m.cfg.Matching, ascii.MatchModes())
+	case fieldProfile:
+		m.cfg.ColorProfile = cycle(m.cfg.ColorProfile, ascii.ColorProfiles())
+	case fieldColorDither:
+		m.cfg.ColorDither = !m.cfg.ColorDither
+	case fieldTolerance:
+		m.cfg.ColorTolerance = clamp(m.cfg.ColorTolerance+step(1), 0, 32)
+	case fieldAlpha:
+		m.cfg.Alpha = cycle(m.cfg.Alpha, ascii.AlphaModes())
+	case fieldCalibrate:
+		m.cfg.Calibrate = !m.cfg.Calibrate
 	case fieldCharSet:
-		m.cfg.Charset = (m.cfg.Charset + ascii.CharSet(1)) % ascii.CharSet(4)
+		m.cfg.Charset = cycle(m.cfg.Charset, ascii.CharSets())
 	default:
 		// do nothing
 	}
@@ -194,6 +274,15 @@ func (m *Model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
 			return m.updateViewer(msg)
 		case modeViewSaveName:
 			return m.updateSaveName(msg)
+		case modeViewFilters:
+			return m.updateFilters(msg)
+		case modeViewCrop:
+			return m.updateCrop(msg)
+		}
+
+	case tea.MouseMsg:
+		if m.mode == modeViewCrop {
+			return m.updateCropMouse(msg)
 		}
 	}
 	return m, nil
@@ -448,8 +537,16 @@ func (m *Model) updateViewer(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
 		m.cfg.Inverted = !m.cfg.Inverted
 		m.recompute()
 	case "d":
-		m.cfg.Dithering = cycleDither(m.cfg.Dithering)
+		m.cfg.Dithering = cycle(m.cfg.Dithering, ascii.DitheringStrategies())
+		m.recompute()
+	case "e":
+		m.cfg.EdgeDetection = !m.cfg.EdgeDetection
 		m.recompute()
+	case "f":
+		m.mode = modeViewFilters
+		m.status = "Editing filters"
+	case "x":
+		return m, m.enterCrop()
 	case "s":
 		if m.res != nil {
 			m.mode = modeViewSaveName
@@ -484,7 +581,7 @@ func (m *Model) View() string {
 	switch m.mode {
 	case modePick, modePickPathInput:
 		return m.viewPicker()
-	case modeView, modeViewSaveName:
+	case modeView, modeViewSaveName, modeViewFilters, modeViewCrop:
 		return m.viewViewer()
 	default:
 		return "invalid mode"
@@ -528,16 +625,52 @@ func (m *Model) viewViewer() string {
 		switch m.focused {
 		case fieldResolution:
 			return "Resolution"
+		case fieldResample:
+			return "Resampling"
+		case fieldPixelArt:
+			return "Pixel art"
+		case fieldRotate:
+			return "Rotation"
+		case fieldFlip:
+			return "Flip"
 		case fieldContrast:
 			return "Contrast"
 		case fieldBrightness:
 			return "Brightness"
+		case fieldGamma:
+			return "Gamma-correct"
+		case fieldLuminance:
+			return "Luminance"
+		case fieldTone:
+			return "Tone mapping"
 		case fieldDither:
 			return "Dithering"
+		case fieldSerpentine:
+			return "Serpentine"
+		case fieldStrength:
+			return "Dither strength"
+		case fieldSeed:
+			return "Noise seed"
+		case fieldCalibrate:
+			return "Calibrated ramp"
 		case fieldColor:
 			return "Color"
+		case fieldAggregate:
+			return "Cell color"
 		case fieldInvert:
 			return "Invert"
+		case fieldEdges:
+			return "Edges"
+		case fieldMatch:
+			return "Matching"
+		case fieldProfile:
+			return "Color profile"
+		case fieldColorDither:
+			return "Color dithering"
+		case fieldTolerance:
+			return "Color tolerance"
+		case fieldAlpha:
+			return "Alpha"
 		default:
 			return "Unknown"
 		}
@@ -553,15 +686,33 @@ func (m *Model) viewViewer() string {
 		return inactiveControlStyle.Render("  " + text + "  ")
 	}
 
-	controlsRow := lipgloss.JoinHorizontal(
-		lipgloss.Top,
+	controlsRow := joinWrapped(
+		m.w,
 		controlChip(fieldResolution, "Res", fmt.Sprintf("%.2f", m.cfg.Resolution)),
+		controlChip(fieldResample, "Resize", m.cfg.Resampling.String()),
+		controlChip(fieldPixelArt, "Pixel", fmt.Sprintf("%v", m.cfg.SizeMode == ascii.SizePixelArt)),
+		controlChip(fieldRotate, "Rot", m.cfg.Rotation.String()),
+		controlChip(fieldFlip, "Flip", flipName(m.cfg.FlipH, m.cfg.FlipV)),
 		controlChip(fieldContrast, "Ctr", fmt.Sprintf("%.2f", m.cfg.Contrast)),
 		controlChip(fieldBrightness, "Brt", fmt.Sprintf("%.2f", m.cfg.Brightness)),
+		controlChip(fieldGamma, "Gamma", fmt.Sprintf("%v", m.cfg.GammaCorrect)),
+		controlChip(fieldLuminance, "Luma", m.cfg.Luminance.String()),
+		controlChip(fieldTone, "Tone", m.cfg.ToneMapping.String()),
 		controlChip(fieldDither, "Dither", ditherName(m.cfg.Dithering)),
+		controlChip(fieldSerpentine, "Serp", fmt.Sprintf("%v", m.cfg.Serpentine)),
+		controlChip(fieldStrength, "Str", fmt.Sprintf("%.2f", m.cfg.DitherStrength)),
+		controlChip(fieldSeed, "Seed", fmt.Sprintf("%d", m.cfg.Seed)),
 		controlChip(fieldCharSet, "Charset", charsetName(m.cfg.Charset)),
+		controlChip(fieldCalibrate, "Calib", fmt.Sprintf("%v", m.cfg.Calibrate)),
 		controlChip(fieldColor, "Color", fmt.Sprintf("%v", m.cfg.Colored)),
+		controlChip(fieldAggregate, "Cell", m.cfg.ColorAggregation.String()),
 		controlChip(fieldInvert, "Invert", fmt.Sprintf("%v", m.cfg.Inverted)),
+		controlChip(fieldEdges, "Edges", fmt.Sprintf("%v", m.cfg.EdgeDetection)),
+		controlChip(fieldMatch, "Match", matchName(m.cfg.Matching)),
+		controlChip(fieldProfile, "Profile", m.cfg.ColorProfile.String()),
+		controlChip(fieldColorDither, "CDither", fmt.Sprintf("%v", m.cfg.ColorDither)),
+		controlChip(fieldTolerance, "Tol", fmt.Sprintf("%.0f", m.cfg.ColorTolerance)),
+		controlChip(fieldAlpha, "Alpha", alphaName(m.cfg.Alpha)),
 	)
 
 	controlsBlock := lipgloss.JoinVertical(
@@ -592,13 +743,21 @@ func (m *Model) viewViewer() string {
 		Render(art)
 
 	var help string
-	if isSaving {
+	if m.mode == modeViewFilters {
+		help = helpStyle.Render(
+			"↑/↓ select filter   ←/→ amount   [/] radius   a add   t type   x remove   J/K move down/up   f/esc close",
+		)
+	} else if m.mode == modeViewCrop {
+		help = helpStyle.Render(
+			"←/→/↑/↓ move box   shift+arrows resize   mouse drag select   r whole image   Enter crop   x/esc cancel",
+		)
+	} else if isSaving {
 		help = helpStyle.Render(
 			"Saving " + strings.ToUpper(m.saveKind) + " – type filename, Enter save, Esc cancel",
 		)
 	} else {
 		help = helpStyle.Render(
-			"←/→ select control   ↑/↓ change value   c color   i invert   d dither   s save html   m save markdown   o open image   q quit",
+			"←/→ select control   ↑/↓ change value   c color   i invert   d dither   e edges   f filters   x crop   s save html   m save markdown   o open image   q quit",
 		)
 	}
 
@@ -609,6 +768,10 @@ func (m *Model) viewViewer() string {
 		help,
 	}
 
+	if m.mode == modeViewFilters {
+		rows = append(rows, saveBoxStyle.Render(m.viewFilters()))
+	}
+
 	if isSaving {
 		cursor := "_"
 		saveInfo := fmt.Sprintf("Save %s file", strings.ToUpper(m.saveKind))
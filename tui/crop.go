@@ -0,0 +1,181 @@
+package tui
+
+import (
+	"fmt"
+	"image"
+
+	"github.com/M1chlCZ/asciicharm-go/pkg/ascii"
+	tea "github.com/charmbracelet/bubbletea"
+)
+
+// Where the art starts on screen: the title, the frame's top margin and
+// border above it, the border and padding left of it.
+const (
+	artOriginX = 2
+	artOriginY = 3
+)
+
+// Number of steps an arrow press moves or resizes the crop box across the
+// image
+const cropSteps = 40
+
+// orientedSize is the size of the image after rotation, the space crop
+// rectangles live in.
+func (m *Model) orientedSize() (int, int) {
+	b := m.img.Bounds()
+	if m.cfg.Rotation == ascii.Rotate90 || m.cfg.Rotation == ascii.Rotate270 {
+		return b.Dy(), b.Dx()
+	}
+	return b.Dx(), b.Dy()
+}
+
+// enterCrop shows the whole image with the current crop as a box.
+func (m *Model) enterCrop() tea.Cmd {
+	if m.img == nil {
+		return nil
+	}
+	w, h := m.orientedSize()
+	m.cropPrev = m.cfg.Crop
+	m.cropSel = m.cfg.Crop
+	if m.cropSel.Empty() {
+		m.cropSel = image.Rect(0, 0, w, h)
+	}
+	m.cfg.Crop = image.Rectangle{}
+	m.mode = modeViewCrop
+	m.recompute()
+	m.status = "Cropping – arrows move, shift+arrows resize, drag with the mouse"
+	return tea.EnableMouseCellMotion
+}
+
+// leaveCrop converts only the selected region, or everything if the box
+// covers the whole image.
+func (m *Model) leaveCrop(crop image.Rectangle) tea.Cmd {
+	w, h := m.orientedSize()
+	if crop == image.Rect(0, 0, w, h) {
+		crop = image.Rectangle{}
+	}
+	m.cfg.Crop = crop
+	m.cropDragging = false
+	m.mode = modeView
+	m.recompute()
+	if crop.Empty() {
+		m.status = "Crop cleared"
+	} else {
+		m.status = fmt.Sprintf("Cropped to %d×%d at %d,%d", crop.Dx(), crop.Dy(), crop.Min.X, crop.Min.Y)
+	}
+	return tea.DisableMouse
+}
+
+func (m *Model) updateCrop(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	w, h := m.orientedSize()
+	stepX, stepY := max(1, w/cropSteps), max(1, h/cropSteps)
+	sel := m.cropSel
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "enter":
+		return m, m.leaveCrop(sel)
+	case "esc", "x":
+		return m, m.leaveCrop(m.cropPrev)
+	case "r":
+		sel = image.Rect(0, 0, w, h)
+
+	case "left":
+		sel = sel.Add(image.Pt(-min(stepX, sel.Min.X), 0))
+	case "right":
+		sel = sel.Add(image.Pt(min(stepX, w-sel.Max.X), 0))
+	case "up":
+		sel = sel.Add(image.Pt(0, -min(stepY, sel.Min.Y)))
+	case "down":
+		sel = sel.Add(image.Pt(0, min(stepY, h-sel.Max.Y)))
+
+	// the bottom-right corner resizes
+	case "shift+left":
+		sel.Max.X = max(sel.Min.X+1, sel.Max.X-stepX)
+	case "shift+right":
+		sel.Max.X = min(w, sel.Max.X+stepX)
+	case "shift+up":
+		sel.Max.Y = max(sel.Min.Y+1, sel.Max.Y-stepY)
+	case "shift+down":
+		sel.Max.Y = min(h, sel.Max.Y+stepY)
+	default:
+		return m, nil
+	}
+
+	m.cropSel = sel
+	m.updateArtString()
+	return m, nil
+}
+
+// updateCropMouse spans the box from where the left button went down to
+// where it is now.
+func (m *Model) updateCropMouse(msg tea.MouseMsg) (tea.Model, tea.Cmd) {
+	if m.res == nil {
+		return m, nil
+	}
+	p := m.cellToPixel(msg.X-artOriginX, msg.Y-artOriginY)
+
+	switch msg.Action {
+	case tea.MouseActionPress:
+		if msg.Button != tea.MouseButtonLeft {
+			return m, nil
+		}
+		m.cropDragging = true
+		m.cropAnchor = p
+	case tea.MouseActionMotion, tea.MouseActionRelease:
+		if !m.cropDragging {
+			return m, nil
+		}
+		w, h := m.orientedSize()
+		r := image.Rectangle{Min: m.cropAnchor, Max: p}.Canon()
+		// both corner cells are inside the box
+		r.Max = r.Max.Add(m.cellToPixel(1, 1))
+		r = r.Intersect(image.Rect(0, 0, w, h))
+		if !r.Empty() {
+			m.cropSel = r
+		}
+		if msg.Action == tea.MouseActionRelease {
+			m.cropDragging = false
+		}
+	}
+	m.updateArtString()
+	return m, nil
+}
+
+// cellToPixel maps a cell of the uncropped preview to image pixels,
+// clamped to the image.
+func (m *Model) cellToPixel(cx, cy int) image.Point {
+	w, h := m.orientedSize()
+	cx = max(0, min(m.res.Width, cx))
+	cy = max(0, min(m.res.Height, cy))
+	return image.Pt(cx*w/m.res.Width, cy*h/m.res.Height)
+}
+
+// cropOverlay draws the crop box over the plain-text preview.
+func (m *Model) cropOverlay() string {
+	res := *m.res
+	res.Chars = append([]rune(nil), m.res.Chars...)
+
+	w, h := m.orientedSize()
+	x0 := m.cropSel.Min.X * res.Width / w
+	y0 := m.cropSel.Min.Y * res.Height / h
+	x1 := max(x0, min(res.Width-1, (m.cropSel.Max.X*res.Width+w-1)/w-1))
+	y1 := max(y0, min(res.Height-1, (m.cropSel.Max.Y*res.Height+h-1)/h-1))
+
+	set := func(x, y int, r rune) { res.Chars[y*res.Width+x] = r }
+	for x := x0; x <= x1; x++ {
+		set(x, y0, '─')
+		set(x, y1, '─')
+	}
+	for y := y0; y <= y1; y++ {
+		set(x0, y, '│')
+		set(x1, y, '│')
+	}
+	set(x0, y0, '┌')
+	set(x1, y0, '┐')
+	set(x0, y1, '└')
+	set(x1, y1, '┘')
+
+	return res.ToPlainText()
+}
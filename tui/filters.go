@@ -0,0 +1,133 @@
+package tui
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/M1chlCZ/asciicharm-go/pkg/ascii"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+)
+
+// Number of steps an arrow press moves a filter parameter across its range
+const filterSteps = 40
+
+func (m *Model) updateFilters(msg tea.KeyMsg) (tea.Model, tea.Cmd) {
+	filters := m.cfg.Filters
+	sel := m.filterSel
+
+	switch msg.String() {
+	case "ctrl+c", "q":
+		return m, tea.Quit
+	case "f", "esc":
+		m.mode = modeView
+		m.status = fmt.Sprintf("Editing %s – use arrows to tweak parameters", m.imgPath)
+		return m, nil
+
+	case "up":
+		if sel > 0 {
+			m.filterSel--
+		}
+		return m, nil
+	case "down":
+		if sel < len(filters)-1 {
+			m.filterSel++
+		}
+		return m, nil
+
+	case "a":
+		// new filters go below the selected one
+		at := min(sel+1, len(filters))
+		f := ascii.DefaultFilter(ascii.FilterBlur)
+		filters = append(filters[:at], append([]ascii.Filter{f}, filters[at:]...)...)
+		m.filterSel = at
+	case "t":
+		if len(filters) == 0 {
+			return m, nil
+		}
+		kinds := ascii.FilterKinds()
+		next := (int(filters[sel].Kind) + 1) % len(kinds)
+		filters[sel] = ascii.DefaultFilter(kinds[next])
+	case "x", "delete", "backspace":
+		if len(filters) == 0 {
+			return m, nil
+		}
+		filters = append(filters[:sel], filters[sel+1:]...)
+		m.filterSel = max(0, min(sel, len(filters)-1))
+	case "K", "shift+up":
+		if sel > 0 {
+			filters[sel-1], filters[sel] = filters[sel], filters[sel-1]
+			m.filterSel--
+		}
+	case "J", "shift+down":
+		if sel < len(filters)-1 {
+			filters[sel+1], filters[sel] = filters[sel], filters[sel+1]
+			m.filterSel++
+		}
+
+	case "left", "right", "[", "]":
+		if len(filters) == 0 {
+			return m, nil
+		}
+		f := &filters[sel]
+		amin, amax, rmin, rmax := f.Kind.Limits()
+		dir := 1.0
+		if msg.String() == "left" || msg.String() == "[" {
+			dir = -1.0
+		}
+		if msg.String() == "left" || msg.String() == "right" {
+			if amax <= amin {
+				return m, nil
+			}
+			f.Amount = clamp(f.Amount+dir*(amax-amin)/filterSteps, amin, amax)
+		} else {
+			if rmax <= rmin {
+				return m, nil
+			}
+			f.Radius = clamp(f.Radius+dir*(rmax-rmin)/filterSteps, rmin, rmax)
+		}
+	default:
+		return m, nil
+	}
+
+	m.cfg.Filters = filters
+	m.recompute()
+	return m, nil
+}
+
+// viewFilters renders the pipeline, one filter per line, in order.
+func (m *Model) viewFilters() string {
+	selStyle := lipgloss.NewStyle().Bold(true).Foreground(lipgloss.Color("11"))
+
+	var b strings.Builder
+	b.WriteString("Filters (applied top to bottom)")
+	if len(m.cfg.Filters) == 0 {
+		b.WriteString("\n  none – press a to add one")
+	}
+	for i, f := range m.cfg.Filters {
+		line := fmt.Sprintf("%d. %s%s", i+1, f.Kind, filterParams(f))
+		if i == m.filterSel {
+			line = selStyle.Render("▶ " + line)
+		} else {
+			line = "  " + line
+		}
+		b.WriteString("\n" + line)
+	}
+	return b.String()
+}
+
+// filterParams describes the parameters the filter's kind uses.
+func filterParams(f ascii.Filter) string {
+	amin, amax, rmin, rmax := f.Kind.Limits()
+	var parts []string
+	if amax > amin {
+		parts = append(parts, fmt.Sprintf("amount %.2f", f.Amount))
+	}
+	if rmax > rmin {
+		parts = append(parts, fmt.Sprintf("radius %.2f", f.Radius))
+	}
+	if len(parts) == 0 {
+		return ""
+	}
+	return "  (" + strings.Join(parts, ", ") + ")"
+}
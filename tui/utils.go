@@ -1,90 +1,144 @@
 package tui
 
 import (
+	"errors"
 	"fmt"
 	"image"
+	"io/fs"
 	"os"
 	"path/filepath"
 	"sort"
-	"strings"
 
 	"github.com/M1chlCZ/asciicharm-go/pkg/ascii"
-	"github.com/disintegration/imaging"
+	"github.com/charmbracelet/lipgloss"
 )
 
+// joinWrapped lays items out left to right, starting a new row whenever
+// the next one would not fit into width.
+func joinWrapped(width int, items ...string) string {
+	var rows []string
+	var row []string
+	rowWidth := 0
+
+	for _, it := range items {
+		w := lipgloss.Width(it)
+		if len(row) > 0 && rowWidth+w > width {
+			rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+			row, rowWidth = nil, 0
+		}
+		row = append(row, it)
+		rowWidth += w
+	}
+	if len(row) > 0 {
+		rows = append(rows, lipgloss.JoinHorizontal(lipgloss.Top, row...))
+	}
+	return lipgloss.JoinVertical(lipgloss.Center, rows...)
+}
+
 func ditherName(d ascii.DitheringStrategy) string {
-	switch d {
-	case ascii.DitheringNone:
-		return "None"
-	case ascii.DitheringFloydSteinberg:
-		return "FS"
-	case ascii.DitheringAtkinson:
-		return "Atkinson"
-	case ascii.DitheringRiemersma:
-		return "Riemersma"
-	case ascii.DitheringOrdered2x2:
-		return "Ord2x2"
-	case ascii.DitheringOrdered4x4:
-		return "Ord4x4"
-	case ascii.DitheringThreshold:
-		return "Thresh"
+	return d.Name()
+}
+
+func charsetName(cs ascii.CharSet) string {
+	return cs.Name()
+}
+
+// CharsetDir is where user ramps are looked for by default, e.g.
+// ~/.config/asciicharm/charsets.
+func CharsetDir() string {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "asciicharm", "charsets")
+}
+
+// LoadCharsets registers the ramps in dir. A missing directory is not an
+// error.
+func LoadCharsets(dir string) error {
+	if dir == "" {
+		return nil
+	}
+	_, err := ascii.LoadCharsets(dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+func matchName(mm ascii.MatchMode) string {
+	switch mm {
+	case ascii.MatchBrightness:
+		return "Ramp"
+	case ascii.MatchShapeSSE:
+		return "Shape"
+	case ascii.MatchShapeSSIM:
+		return "SSIM"
 	default:
 		return "?"
 	}
 }
 
-func cycleDither(d ascii.DitheringStrategy) ascii.DitheringStrategy {
-	switch d {
-	case ascii.DitheringNone:
-		return ascii.DitheringFloydSteinberg
-	case ascii.DitheringFloydSteinberg:
-		return ascii.DitheringAtkinson
-	case ascii.DitheringAtkinson:
-		return ascii.DitheringRiemersma
-	case ascii.DitheringRiemersma:
-		return ascii.DitheringOrdered2x2
-	case ascii.DitheringOrdered2x2:
-		return ascii.DitheringOrdered4x4
-	case ascii.DitheringOrdered4x4:
-		return ascii.DitheringThreshold
-	case ascii.DitheringThreshold:
-		fallthrough
+func alphaName(a ascii.AlphaMode) string {
+	switch a {
+	case ascii.AlphaIgnore:
+		return "Ignore"
+	case ascii.AlphaMatte:
+		return "Matte"
+	case ascii.AlphaKeep:
+		return "Keep"
 	default:
-		return ascii.DitheringNone
+		return "?"
 	}
 }
 
-func charsetName(cs ascii.CharSet) string {
-	switch cs {
-	case ascii.CharSetClassic:
-		return "Classic"
-	case ascii.CharSetPhoto:
-		return "Photo"
-	case ascii.CharSetMinimal:
-		return "Minimal"
-	case ascii.CharSetBlocks:
-		return "Blocks"
+func flipName(h, v bool) string {
+	switch {
+	case h && v:
+		return "Both"
+	case h:
+		return "H"
+	case v:
+		return "V"
 	default:
-		return "?"
+		return "None"
 	}
 }
 
+// LoadImage opens an image, "-" reading it from stdin, turned upright by
+// its EXIF orientation.
 func LoadImage(path string) (image.Image, error) {
-	img, err := imaging.Open(path)
+	r := os.Stdin
+	if path != "-" {
+		f, err := os.Open(path)
+		if err != nil {
+			return nil, fmt.Errorf("open image: %w", err)
+		}
+		defer f.Close()
+		r = f
+	}
+
+	img, err := ascii.DecodeImage(r)
 	if err != nil {
 		return nil, fmt.Errorf("open image: %w", err)
 	}
 	return img, nil
 }
 
-func isImageFile(name string) bool {
-	ext := strings.ToLower(filepath.Ext(name))
-	switch ext {
-	case ".png", ".jpg", ".jpeg", ".gif", ".webp":
-		return true
-	default:
+// isImageFile reports whether the file's content is an image LoadImage
+// can decode. Only regular files are opened, also behind symlinks, since
+// opening pipes or devices could block.
+func isImageFile(path string) bool {
+	if fi, err := os.Stat(path); err != nil || !fi.Mode().IsRegular() {
 		return false
 	}
+	f, err := os.Open(path)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	_, err = ascii.DetectFormat(f)
+	return err == nil
 }
 
 func ListImageFiles(dir string) ([]string, error) {
@@ -94,13 +148,20 @@ func ListImageFiles(dir string) ([]string, error) {
 	}
 	var files []string
 	for _, e := range entries {
-		if e.IsDir() {
-			continue
-		}
-		if isImageFile(e.Name()) {
+		if isImageFile(filepath.Join(dir, e.Name())) {
 			files = append(files, e.Name())
 		}
 	}
 	sort.Strings(files)
 	return files, nil
 }
+
+// cycle steps to the value after v in all, wrapping around.
+func cycle[T comparable](v T, all []T) T {
+	for i, x := range all {
+		if x == v {
+			return all[(i+1)%len(all)]
+		}
+	}
+	return all[0]
+}